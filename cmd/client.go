@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/secrets"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/spf13/viper"
+)
+
+// ErrRecordAndReplay is returned when both recording and replaying are enabled.
+var ErrRecordAndReplay = errors.New("record_dir and replay_dir cannot both be set")
+
+// clientOptions returns the Command Center client options for the configuration, resolving the tokens
+// referencing secrets, paginating the stores, and recording or replaying the API responses if enabled.
+func clientOptions() ([]commandcenter.Option, error) {
+	recordDir := viper.GetString("record_dir")
+	replayDir := viper.GetString("replay_dir")
+
+	var opts []commandcenter.Option
+	if pageSize := viper.GetInt("page_size"); pageSize > 0 {
+		opts = append(opts, commandcenter.WithPageSize(pageSize))
+	}
+
+	switch {
+	case recordDir != "" && replayDir != "":
+		return nil, ErrRecordAndReplay
+	case replayDir != "":
+		slog.Info("replaying Command Center API responses", "dir", replayDir)
+
+		// The tokens are not resolved, as the API is not called.
+		return append(opts, commandcenter.WithTransport(commandcenter.NewReplayTransport(replayDir))), nil
+	}
+
+	var secretsConfig secrets.Config
+	if err := viper.UnmarshalKey("secrets", &secretsConfig); err != nil {
+		return nil, fmt.Errorf("could not unmarshal secrets config: %w", err)
+	}
+
+	opts = append(opts, commandcenter.WithTokenSource(secrets.NewResolver(secretsConfig)))
+
+	if recordDir != "" {
+		slog.Info("recording Command Center API responses", "dir", recordDir)
+
+		opts = append(opts, commandcenter.WithTransport(commandcenter.NewRecordingTransport(recordDir, nil)))
+	}
+
+	return opts, nil
+}
+
+// newLimiters returns the limiters of the requests to each Command Center for the configuration.
+func newLimiters() (*commandcenter.Limiters, error) {
+	var rateLimit commandcenter.RateLimitConfig
+	if err := viper.UnmarshalKey("rate_limit", &rateLimit); err != nil {
+		return nil, fmt.Errorf("could not unmarshal rate_limit config: %w", err)
+	}
+
+	var breaker commandcenter.BreakerConfig
+	if err := viper.UnmarshalKey("circuit_breaker", &breaker); err != nil {
+		return nil, fmt.Errorf("could not unmarshal circuit_breaker config: %w", err)
+	}
+
+	return commandcenter.NewLimiters(rateLimit, breaker), nil
+}
+
+// newCoalescer returns the coalescer of identical requests to the Command Centers for the configuration,
+// or nil if coalescing is disabled.
+func newCoalescer() (*commandcenter.Coalescer, error) {
+	var coalesceConfig commandcenter.CoalesceConfig
+	if err := viper.UnmarshalKey("coalesce", &coalesceConfig); err != nil {
+		return nil, fmt.Errorf("could not unmarshal coalesce config: %w", err)
+	}
+
+	if !coalesceConfig.Enabled {
+		return nil, nil //nolint:nilnil // coalescing is disabled
+	}
+
+	return commandcenter.NewCoalescer(coalesceConfig), nil
+}
+
+// clientFunc returns a function creating Command Center clients with the options, for the storage metrics.
+// The clients of a target and its discovered clouds share one HTTP client.
+func clientFunc(opts []commandcenter.Option) metrics.ClientFunc {
+	pool := commandcenter.NewPool(opts...)
+
+	return func(_ context.Context, target *config.Target) metrics.ZadaraClient {
+		return pool.Client(target)
+	}
+}
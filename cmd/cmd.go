@@ -33,10 +33,30 @@ func NewRootCommand(version, commit string) *cobra.Command {
 	cmd.PersistentFlags().String("config", "", "The path to the configuration file")
 	cmd.PersistentFlags().String("log-level", "info", "The path to the configuration file")
 
+	cmd.PersistentFlags().String("record-dir", "",
+		"Record the Command Center API responses to this directory, without the tokens")
+	cmd.PersistentFlags().String("replay-dir", "",
+		"Replay the Command Center API responses recorded to this directory instead of calling the API")
+
+	cmd.PersistentFlags().StringArray("target", nil,
+		"A target to collect, as name=...,url=...,cloud_name=...,token_file=..., replacing any with the same name")
+
+	// Setting both in the config file or environment is rejected when the clients are created.
+	cmd.MarkFlagsMutuallyExclusive("record-dir", "replay-dir")
+
 	must(viper.BindPFlag("config", cmd.PersistentFlags().Lookup("config")))
 	must(viper.BindPFlag("log-level", cmd.PersistentFlags().Lookup("log-level")))
+	must(viper.BindPFlag("record_dir", cmd.PersistentFlags().Lookup("record-dir")))
+	must(viper.BindPFlag("replay_dir", cmd.PersistentFlags().Lookup("replay-dir")))
+	must(viper.BindPFlag("target", cmd.PersistentFlags().Lookup("target")))
 
 	cmd.AddCommand(NewServerCommand())
+	cmd.AddCommand(NewRulesCommand())
+	cmd.AddCommand(NewDashboardCommand())
+	cmd.AddCommand(NewScrapeCommand())
+	cmd.AddCommand(NewStoresCommand())
+	cmd.AddCommand(NewPoliciesCommand())
+	cmd.AddCommand(NewSimulateCommand())
 
 	return cmd
 }
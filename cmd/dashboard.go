@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/dashboard"
+	"github.com/spf13/cobra"
+)
+
+func newDashboardGenerateCommand() *cobra.Command {
+	var (
+		dashboardConfig dashboard.Config
+		output          string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a Grafana dashboard for the exported metrics",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := config.Setup(); err != nil {
+				return fmt.Errorf("error setting up config: %w", err)
+			}
+
+			dashboardConfig.Namespace = namespaceFlag(cmd)
+
+			labelConfig, err := labelConfig()
+			if err != nil {
+				return err
+			}
+
+			if err := labelConfig.Validate(nil); err != nil {
+				return fmt.Errorf("invalid labels config: %w", err)
+			}
+
+			dashboardConfig.Labels = labelConfig
+
+			w, err := openOutput(output)
+			if err != nil {
+				return err
+			}
+
+			defer func() {
+				if err := w.Close(); err != nil {
+					slog.Error("error closing output", "error", err)
+				}
+			}()
+
+			return dashboard.Write(w, dashboard.Generate(dashboardConfig)) //nolint:wrapcheck // already wrapped by dashboard
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "The file to write the dashboard to")
+	cmd.Flags().String("namespace", "", "The namespace the metrics are exported with")
+	cmd.Flags().StringVar(&dashboardConfig.Title, "title", dashboard.DefaultTitle, "The title of the dashboard")
+	cmd.Flags().StringVar(&dashboardConfig.UID, "uid", dashboard.DefaultUID, "The unique identifier of the dashboard")
+
+	return cmd
+}
+
+// NewDashboardCommand creates a new dashboard command for the zadara-exporter.
+func NewDashboardCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Work with Grafana dashboards for the exported metrics",
+	}
+
+	cmd.AddCommand(newDashboardGenerateCommand())
+
+	return cmd
+}
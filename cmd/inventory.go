@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/spf13/cobra"
+)
+
+type (
+	// storeRecord represents a store in the stores list output.
+	storeRecord struct {
+		Target    string `json:"target"     yaml:"target"`
+		CloudName string `json:"cloud_name" yaml:"cloud_name"`
+		ID        int    `json:"id"         yaml:"id"`
+		Name      string `json:"name"       yaml:"name"`
+		Status    string `json:"status"     yaml:"status"`
+		Tenant    string `json:"tenant"     yaml:"tenant"`
+		Objects   int64  `json:"objects"    yaml:"objects"`
+		Drives    int64  `json:"drives"     yaml:"drives"`
+	}
+
+	// policyRecord represents a storage policy in the policies list output.
+	policyRecord struct {
+		Target             string  `json:"target"              yaml:"target"`
+		CloudName          string  `json:"cloud_name"          yaml:"cloud_name"`
+		Store              string  `json:"store"               yaml:"store"`
+		ID                 int     `json:"id"                  yaml:"id"`
+		Name               string  `json:"name"                yaml:"name"`
+		Status             string  `json:"status"              yaml:"status"`
+		HealthStatus       string  `json:"health_status"       yaml:"health_status"`
+		HealthPercentage   float64 `json:"health_percentage"   yaml:"health_percentage"`
+		FreeCapacity       int64   `json:"free_capacity"       yaml:"free_capacity"`
+		UsedCapacity       int64   `json:"used_capacity"       yaml:"used_capacity"`
+		NormalPercentage   float64 `json:"normal_percentage"   yaml:"normal_percentage"`
+		DegradedPercentage float64 `json:"degraded_percentage" yaml:"degraded_percentage"`
+		CriticalPercentage float64 `json:"critical_percentage" yaml:"critical_percentage"`
+	}
+)
+
+// ErrUnknownStore is returned when no store has the requested name or ID.
+var ErrUnknownStore = errors.New("unknown store")
+
+// inventoryTargets loads the configuration and returns the target with the name, or all targets.
+func inventoryTargets(ctx context.Context, name string) ([]*config.Target, error) {
+	if err := config.Setup(); err != nil {
+		return nil, fmt.Errorf("error setting up config: %w", err)
+	}
+
+	targets, err := loadTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectTargets(targets, name)
+}
+
+// cloudTargets returns the targets with the targets whose clouds are discovered replaced by one per cloud.
+func cloudTargets(
+	ctx context.Context,
+	targets []*config.Target,
+	opts []commandcenter.Option,
+) ([]*config.Target, error) {
+	var expanded []*config.Target
+
+	for _, target := range targets {
+		clouds, err := commandcenter.NewClient(target, opts...).CloudTargets(ctx, target)
+		if err != nil {
+			return nil, fmt.Errorf("error discovering clouds for %s: %w", target.Name, err)
+		}
+
+		expanded = append(expanded, clouds...)
+	}
+
+	return expanded, nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func storeRow(r storeRecord) []string {
+	return []string{
+		r.Target, r.CloudName, strconv.Itoa(r.ID), r.Name, r.Status, r.Tenant,
+		strconv.FormatInt(r.Objects, 10), strconv.FormatInt(r.Drives, 10),
+	}
+}
+
+func policyRow(r policyRecord) []string {
+	return []string{
+		r.Target, r.CloudName, r.Store, strconv.Itoa(r.ID), r.Name, r.Status, r.HealthStatus,
+		formatFloat(r.HealthPercentage), strconv.FormatInt(r.FreeCapacity, 10), strconv.FormatInt(r.UsedCapacity, 10),
+		formatFloat(r.NormalPercentage), formatFloat(r.DegradedPercentage), formatFloat(r.CriticalPercentage),
+	}
+}
+
+// listStores returns the stores of the targets.
+func listStores(ctx context.Context, targets []*config.Target) ([]storeRecord, error) {
+	clientOpts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err = cloudTargets(ctx, targets, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []storeRecord
+
+	for _, target := range targets {
+		res, err := commandcenter.NewClient(target, clientOpts...).GetStores(ctx, target.CloudName)
+		if err != nil {
+			return nil, fmt.Errorf("error getting stores for %s: %w", target.Name, err)
+		}
+
+		for _, store := range res.Zioses {
+			records = append(records, storeRecord{
+				Target:    target.Name,
+				CloudName: target.CloudName,
+				ID:        store.ID,
+				Name:      store.Name,
+				Status:    store.Status,
+				Tenant:    store.TenantName,
+				Objects:   store.ObjectsCount,
+				Drives:    store.Drives,
+			})
+		}
+	}
+
+	return records, nil
+}
+
+// matchStore reports whether the store has the name or ID, or the name is empty.
+func matchStore(store *vpsaobjectstorage.Zios, name string) bool {
+	return name == "" || store.Name == name || strconv.Itoa(store.ID) == name
+}
+
+// listPolicies returns the storage policies of the store with the name or ID, or of all stores.
+func listPolicies(ctx context.Context, targets []*config.Target, storeName string) ([]policyRecord, error) {
+	clientOpts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err = cloudTargets(ctx, targets, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		records []policyRecord
+		found   bool
+	)
+
+	for _, target := range targets {
+		client := commandcenter.NewClient(target, clientOpts...)
+
+		stores, err := client.GetStores(ctx, target.CloudName)
+		if err != nil {
+			return nil, fmt.Errorf("error getting stores for %s: %w", target.Name, err)
+		}
+
+		for _, store := range stores.Zioses {
+			if !matchStore(store, storeName) {
+				continue
+			}
+
+			found = true
+
+			policies, err := client.GetStoragePolicies(ctx, target.CloudName, store.ID)
+			if err != nil {
+				return nil, fmt.Errorf("error getting storage policies for %s: %w", store.Name, err)
+			}
+
+			for _, policy := range policies.ZiosStoragePolicies {
+				records = append(records, policyRecord{
+					Target:             target.Name,
+					CloudName:          target.CloudName,
+					Store:              store.Name,
+					ID:                 policy.ID,
+					Name:               policy.Name,
+					Status:             policy.Status,
+					HealthStatus:       policy.HealthStatus,
+					HealthPercentage:   policy.HealthPercentage,
+					FreeCapacity:       policy.FreeCapacity,
+					UsedCapacity:       policy.UsedCapacity,
+					NormalPercentage:   policy.RingBalance.NormalPercentage,
+					DegradedPercentage: policy.RingBalance.DegradedPercentage,
+					CriticalPercentage: policy.RingBalance.CriticalPercentage,
+				})
+			}
+		}
+	}
+
+	if storeName != "" && !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStore, storeName)
+	}
+
+	return records, nil
+}
+
+func newStoresListCommand() *cobra.Command {
+	var target, output string
+
+	cmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List the object stores of the targets",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			targets, err := inventoryTargets(cmd.Context(), target)
+			if err != nil {
+				return err
+			}
+
+			records, err := listStores(cmd.Context(), targets)
+			if err != nil {
+				return err
+			}
+
+			return writeRecords(os.Stdout, output, records,
+				[]string{"TARGET", "CLOUD", "ID", "NAME", "STATUS", "TENANT", "OBJECTS", "DRIVES"}, storeRow)
+		},
+	}
+
+	cmd.Flags().StringVar(&target, "only", "", "The name of the target to list, or all targets if not set")
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "The output format, either table, json, yaml or csv")
+
+	return cmd
+}
+
+func newPoliciesListCommand() *cobra.Command {
+	var target, store, output string
+
+	cmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List the storage policies of the object stores",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			targets, err := inventoryTargets(cmd.Context(), target)
+			if err != nil {
+				return err
+			}
+
+			records, err := listPolicies(cmd.Context(), targets, store)
+			if err != nil {
+				return err
+			}
+
+			return writeRecords(os.Stdout, output, records, []string{
+				"TARGET", "CLOUD", "STORE", "ID", "NAME", "STATUS", "HEALTH", "HEALTH %",
+				"FREE", "USED", "NORMAL %", "DEGRADED %", "CRITICAL %",
+			}, policyRow)
+		},
+	}
+
+	cmd.Flags().StringVar(&target, "only", "", "The name of the target to list, or all targets if not set")
+	cmd.Flags().StringVarP(&store, "store", "s", "", "The name or ID of the store to list, or all stores if not set")
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "The output format, either table, json, yaml or csv")
+
+	return cmd
+}
+
+// NewStoresCommand creates a new stores command for the zadara-exporter.
+func NewStoresCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stores",
+		Short: "Work with the object stores of the targets",
+	}
+
+	cmd.AddCommand(newStoresListCommand())
+
+	return cmd
+}
+
+// NewPoliciesCommand creates a new policies command for the zadara-exporter.
+func NewPoliciesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policies",
+		Short: "Work with the storage policies of the object stores",
+	}
+
+	cmd.AddCommand(newPoliciesListCommand())
+
+	return cmd
+}
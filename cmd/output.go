@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownFormat is returned when an unknown output format is requested.
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// openOutput returns a writer for the output path, or stdout if the path is empty or "-".
+func openOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %w", err)
+	}
+
+	return f, nil
+}
+
+// nopCloser wraps a writer that should not be closed, such as stdout.
+type nopCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopCloser) Close() error { return nil }
+
+// namespaceFlag returns the namespace from the command's flag if set,
+// otherwise the namespace from the configuration.
+func namespaceFlag(cmd *cobra.Command) string {
+	if flag := cmd.Flags().Lookup("namespace"); flag != nil && flag.Changed {
+		return flag.Value.String()
+	}
+
+	return viper.GetString("namespace")
+}
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputCSV   = "csv"
+)
+
+// writeRecords writes the records in the format, either as a table or CSV with the header and a row per record,
+// or as JSON or YAML using the records' struct tags.
+func writeRecords[T any](w io.Writer, format string, records []T, header []string, row func(T) []string) error {
+	switch format {
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd // column padding
+
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+		for _, record := range records {
+			fmt.Fprintln(tw, strings.Join(row(record), "\t"))
+		}
+
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("error writing table: %w", err)
+		}
+	case outputCSV:
+		cw := csv.NewWriter(w)
+
+		if err := cw.Write(header); err != nil {
+			return fmt.Errorf("error writing csv: %w", err)
+		}
+
+		for _, record := range records {
+			if err := cw.Write(row(record)); err != nil {
+				return fmt.Errorf("error writing csv: %w", err)
+			}
+		}
+
+		cw.Flush()
+
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("error writing csv: %w", err)
+		}
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(records); err != nil {
+			return fmt.Errorf("error encoding json: %w", err)
+		}
+	case outputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2) //nolint:mnd // matches the rules output
+
+		if err := encoder.Encode(records); err != nil {
+			return fmt.Errorf("error encoding yaml: %w", err)
+		}
+
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("error encoding yaml: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/rules"
+	"github.com/spf13/cobra"
+)
+
+const (
+	rulesFormatFile           = "rules"
+	rulesFormatPrometheusRule = "prometheusrule"
+)
+
+func newRulesGenerateCommand() *cobra.Command {
+	var (
+		ruleConfig rules.Config
+		output     string
+		format     string
+		name       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate Prometheus recording and alerting rules for the exported metrics",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := config.Setup(); err != nil {
+				return fmt.Errorf("error setting up config: %w", err)
+			}
+
+			ruleConfig.Namespace = namespaceFlag(cmd)
+
+			labelConfig, err := labelConfig()
+			if err != nil {
+				return err
+			}
+
+			if err := labelConfig.Validate(nil); err != nil {
+				return fmt.Errorf("invalid labels config: %w", err)
+			}
+
+			ruleConfig.Labels = labelConfig
+
+			file := rules.Generate(ruleConfig)
+
+			var doc any
+
+			switch format {
+			case rulesFormatFile:
+				doc = file
+			case rulesFormatPrometheusRule:
+				doc = rules.WrapPrometheusRule(file, name)
+			default:
+				return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+			}
+
+			w, err := openOutput(output)
+			if err != nil {
+				return err
+			}
+
+			defer func() {
+				if err := w.Close(); err != nil {
+					slog.Error("error closing output", "error", err)
+				}
+			}()
+
+			return rules.Write(w, doc) //nolint:wrapcheck // already wrapped by rules
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "The file to write the rules to")
+	cmd.Flags().StringVar(&format, "format", rulesFormatFile,
+		"The format of the rules, either rules or prometheusrule")
+	cmd.Flags().StringVar(&name, "name", "zadara-exporter", "The name of the PrometheusRule resource")
+	cmd.Flags().String("namespace", "", "The namespace the metrics are exported with")
+	cmd.Flags().StringVar(&ruleConfig.Job, "job", rules.DefaultJob,
+		"A regular expression matching the job label of the exporter")
+	cmd.Flags().Float64Var(&ruleConfig.FreeCapacityThreshold, "free-capacity-threshold",
+		rules.DefaultFreeCapacityThreshold, "The ratio of free capacity to alert below")
+	cmd.Flags().Float64Var(&ruleConfig.HealthThreshold, "health-threshold",
+		rules.DefaultHealthThreshold, "The health ratio to alert below")
+	cmd.Flags().Float64Var(&ruleConfig.DegradedThreshold, "degraded-threshold",
+		rules.DefaultDegradedThreshold, "The degraded ring balance ratio to alert above")
+	cmd.Flags().DurationVar(&ruleConfig.For, "for", rules.DefaultFor,
+		"How long an alert condition must hold before firing")
+
+	return cmd
+}
+
+// NewRulesCommand creates a new rules command for the zadara-exporter.
+func NewRulesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Work with Prometheus rules for the exported metrics",
+	}
+
+	cmd.AddCommand(newRulesGenerateCommand())
+
+	return cmd
+}
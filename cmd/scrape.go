@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/spf13/cobra"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// scrapeFormatText is the Prometheus text exposition format.
+const scrapeFormatText = "text"
+
+// ErrUnknownTarget is returned when no target has the requested name.
+var ErrUnknownTarget = errors.New("unknown target")
+
+// selectTargets returns the target with the name, or all targets if the name is empty.
+func selectTargets(targets []*config.Target, name string) ([]*config.Target, error) {
+	if name == "" {
+		return targets, nil
+	}
+
+	for _, target := range targets {
+		if target.Name == name {
+			return []*config.Target{target}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownTarget, name)
+}
+
+// scrape collects the storage metrics from the targets once, using a manual reader.
+// If some targets could not be collected, the samples of the others are returned with their errors.
+func scrape(ctx context.Context, namespace string, targets []*config.Target) ([]metrics.Sample, error) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	defer func() {
+		if err := provider.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			slog.Error("error shutting down meter provider", "error", err)
+		}
+	}()
+
+	clientOpts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := metricsOptions(clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = metrics.RegisterStorageMetricsWithMeter(provider.Meter("zadara"), targets, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error registering storage metrics: %w", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+
+	err = reader.Collect(ctx, &rm)
+	if err != nil {
+		err = fmt.Errorf("error collecting metrics: %w", err)
+	}
+
+	return metrics.Samples(namespace, &rm), err
+}
+
+// sampleRow returns the table row of the sample.
+func sampleRow(sample metrics.Sample) []string {
+	names := make([]string, 0, len(sample.Labels))
+	for name := range sample.Labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+sample.Labels[name])
+	}
+
+	return []string{sample.Name, strings.Join(pairs, " "), strconv.FormatFloat(sample.Value, 'f', -1, 64)}
+}
+
+// writeSamples writes the samples to the writer in the Prometheus text format, or as records.
+func writeSamples(w io.Writer, format string, samples []metrics.Sample) error {
+	if format == scrapeFormatText {
+		return metrics.WriteText(w, samples) //nolint:wrapcheck // already wrapped by metrics
+	}
+
+	return writeRecords(w, format, samples, []string{"METRIC", "LABELS", "VALUE"}, sampleRow)
+}
+
+// NewScrapeCommand creates a new scrape command for the zadara-exporter.
+func NewScrapeCommand() *cobra.Command {
+	var (
+		target string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:          "scrape",
+		Short:        "Collect the metrics from the targets once and print them",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := config.Setup(); err != nil {
+				return fmt.Errorf("error setting up config: %w", err)
+			}
+
+			targets, err := loadTargets(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			targets, err = selectTargets(targets, target)
+			if err != nil {
+				return err
+			}
+
+			// The samples of the targets that were collected are printed even if others failed,
+			// and the errors of the others are returned so the command exits non-zero.
+			samples, scrapeErr := scrape(cmd.Context(), namespaceFlag(cmd), targets)
+			if scrapeErr != nil && len(samples) == 0 {
+				return scrapeErr
+			}
+
+			if err := writeSamples(os.Stdout, format, samples); err != nil {
+				return err
+			}
+
+			return scrapeErr
+		},
+	}
+
+	cmd.Flags().StringVar(&target, "only", "", "The name of the target to scrape, or all targets if not set")
+	cmd.Flags().StringVarP(&format, "format", "f", scrapeFormatText,
+		"The format to print the metrics in, either text, table, json, yaml or csv")
+	cmd.Flags().String("namespace", "", "The namespace the metrics are exported with")
+
+	return cmd
+}
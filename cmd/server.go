@@ -2,16 +2,20 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/forecast"
 	"github.com/krystal/zadara-exporter/health"
+	"github.com/krystal/zadara-exporter/lifecycle"
 	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/web"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -24,13 +28,31 @@ func must(err error) {
 	}
 }
 
-func serve(ctx context.Context) error {
+// newServer creates the metrics server and the functions to run and gracefully shut it down.
+func newServer(
+	clientOpts []commandcenter.Option,
+	deadlines *metrics.ScrapeDeadlines,
+	targets metrics.TargetsFunc,
+) (lifecycle.RunFunc, lifecycle.ShutdownFunc, error) {
+	var webConfig web.Config
+	if err := viper.UnmarshalKey("web", &webConfig); err != nil {
+		return nil, nil, fmt.Errorf("could not unmarshal web config: %w", err)
+	}
+
+	if err := webConfig.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid web config: %w", err)
+	}
+
 	mux := http.NewServeMux()
 
 	// Create a new HTTP handler for serving the metrics.
-	mux.Handle(viper.GetString("listen_path"), promhttp.Handler())
+	if viper.GetBool("prometheus.enabled") {
+		mux.Handle(viper.GetString("listen_path"),
+			metrics.ScrapeTimeoutHandler(promhttp.Handler(), viper.GetDuration("scrape_timeout_offset"), deadlines))
+	}
+
 	// Register the health handler.
-	health.RegisterHandler(mux, viper.GetString("health_path"))
+	health.RegisterHandlerWithTargets(mux, viper.GetString("health_path"), targets, clientOpts...)
 
 	const ReadHeaderTimeout = 10 * time.Second
 
@@ -40,68 +62,231 @@ func serve(ctx context.Context) error {
 		ReadHeaderTimeout: ReadHeaderTimeout,
 	}
 
-	slog.Info("starting Metrics server",
-		"address", viper.GetString("listen_address"),
-		"path", viper.GetString("listen_path"),
-	)
+	run := func() error {
+		slog.Info("starting Metrics server",
+			"address", viper.GetString("listen_address"),
+			"path", viper.GetString("listen_path"),
+			"tls", webConfig.TLSServerConfig != nil,
+			"basic_auth", len(webConfig.BasicAuthUsers) > 0,
+		)
 
-	// Start the HTTP server in a separate goroutine.
-	go func() {
-		err := server.ListenAndServe()
-		if err != nil {
-			log.Fatalf("error starting HTTP server: %v", err)
+		err := web.ListenAndServe(server, webConfig)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("error starting HTTP server: %w", err)
 		}
-	}()
 
-	// Wait for the context to be done.
-	<-ctx.Done()
+		return nil
+	}
+
+	// Shutdown waits for in-flight scrapes to finish.
+	shutdown := func(ctx context.Context) error {
+		if err := server.Shutdown(ctx); err != nil {
+			return fmt.Errorf("error shutting down HTTP server: %w", err)
+		}
+
+		return nil
+	}
+
+	return run, shutdown, nil
+}
+
+// exporterConfig returns the metric exporter configuration.
+func exporterConfig() (metrics.ExporterConfig, error) {
+	exporterConfig := metrics.ExporterConfig{
+		Namespace:  viper.GetString("namespace"),
+		Prometheus: viper.GetBool("prometheus.enabled"),
+	}
+
+	if err := viper.UnmarshalKey("otlp", &exporterConfig.OTLP); err != nil {
+		return exporterConfig, fmt.Errorf("could not unmarshal otlp config: %w", err)
+	}
+
+	if err := viper.UnmarshalKey("remote_write", &exporterConfig.RemoteWrite); err != nil {
+		return exporterConfig, fmt.Errorf("could not unmarshal remote_write config: %w", err)
+	}
+
+	return exporterConfig, nil
+}
+
+// labelConfig returns the configuration of the built-in labels of the storage metrics.
+func labelConfig() (metrics.LabelConfig, error) {
+	var labelConfig metrics.LabelConfig
+	if err := viper.UnmarshalKey("labels", &labelConfig); err != nil {
+		return labelConfig, fmt.Errorf("could not unmarshal labels config: %w", err)
+	}
+
+	return labelConfig, nil
+}
+
+// metricsOptions returns the storage metrics options for the configuration shared by the server and scrape.
+func metricsOptions(clientOpts []commandcenter.Option) ([]metrics.Option, error) {
+	labelConfig, err := labelConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var filterConfig metrics.FilterConfig
+	if err := viper.UnmarshalKey("metrics", &filterConfig); err != nil {
+		return nil, fmt.Errorf("could not unmarshal metrics config: %w", err)
+	}
 
-	// Shutdown the HTTP server gracefully.
-	err := server.Shutdown(ctx)
+	filter, err := metrics.NewFilter(filterConfig)
 	if err != nil {
-		return fmt.Errorf("error shutting down HTTP server: %w", err)
+		return nil, fmt.Errorf("invalid metrics config: %w", err)
 	}
 
-	return nil
+	opts := []metrics.Option{
+		metrics.WithClientFunc(clientFunc(clientOpts)),
+		metrics.WithLabels(labelConfig),
+		metrics.WithFilter(filter),
+	}
+
+	if viper.GetBool("legacy_metric_names") {
+		opts = append(opts, metrics.WithLegacyNames())
+	}
+
+	return opts, nil
 }
 
-// NewServerCommand creates a new server command for the zadara-exporter.
-func NewServerCommand() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "server",
-		Short: "Start the Zadara exporter server",
-		Run: func(cmd *cobra.Command, _ []string) {
-			if err := config.Setup(); err != nil {
-				slog.Error("error setting up config", "error", err)
+// storageMetricsOptions returns the storage metrics options for the configuration,
+// and the forecaster if forecasting is enabled.
+func storageMetricsOptions(clientOpts []commandcenter.Option) ([]metrics.Option, *forecast.Forecaster, error) {
+	opts, err := metricsOptions(clientOpts)
+	if err != nil {
+		return nil, nil, err
+	}
 
-				return
-			}
+	var forecaster *forecast.Forecaster
 
-			err := metrics.SetupPrometheusExporter(viper.GetString("namespace"))
-			if err != nil {
-				slog.Error("error setting up prometheus exporter", "error", err)
+	if viper.GetBool("forecast.enabled") {
+		var forecastConfig forecast.Config
+		if err := viper.UnmarshalKey("forecast", &forecastConfig); err != nil {
+			return nil, nil, fmt.Errorf("could not unmarshal forecast config: %w", err)
+		}
 
-				return
-			}
+		forecaster = forecast.New(forecastConfig)
+		if err := forecaster.Load(); err != nil {
+			slog.Warn("could not load forecast state, starting with no samples", "error", err)
+		}
 
-			targets, err := config.GetTargets()
-			if err != nil {
-				slog.Error("error unmarshalling targets", "error", err)
+		opts = append(opts, metrics.WithForecaster(forecaster))
+	}
 
-				return
-			}
+	return opts, forecaster, nil
+}
 
-			if err := metrics.RegisterStorageMetrics(targets); err != nil {
-				slog.Error("error registering storage metrics", "error", err)
+// runServer sets up the exporter and runs the metrics server until the context is done or it fails.
+func runServer(ctx context.Context) error {
+	if err := config.Setup(); err != nil {
+		return fmt.Errorf("error setting up config: %w", err)
+	}
 
-				return
-			}
+	targets, err := config.GetTargets()
+	if err != nil {
+		return fmt.Errorf("error unmarshalling targets: %w", err)
+	}
 
-			if err := serve(cmd.Context()); err != nil {
-				slog.Error("error serving metrics", "error", err)
+	discoverer, err := newDiscoverer(targets)
+	if err != nil {
+		return err
+	}
 
-				return
-			}
+	// The discovered targets are refreshed while the exporter runs, so it starts even if they cannot be loaded.
+	if err := discoverer.Refresh(ctx); err != nil {
+		slog.Error("error discovering targets", "error", err)
+	}
+
+	clientOpts, err := clientOptions()
+	if err != nil {
+		return err
+	}
+
+	// The limiters and coalescer are shared by the scrapes and health probes,
+	// so they apply to all the requests to a Command Center.
+	limiters, err := newLimiters()
+	if err != nil {
+		return err
+	}
+
+	clientOpts = append(clientOpts, commandcenter.WithLimiters(limiters))
+
+	coalescer, err := newCoalescer()
+	if err != nil {
+		return err
+	}
+
+	if coalescer != nil {
+		clientOpts = append(clientOpts, commandcenter.WithCoalescer(coalescer))
+	}
+
+	if viper.GetBool("verify_tokens") {
+		verifyTokens(ctx, discoverer.Targets(), clientOpts)
+	}
+
+	opts, forecaster, err := storageMetricsOptions(clientOpts)
+	if err != nil {
+		return fmt.Errorf("error configuring storage metrics: %w", err)
+	}
+
+	deadlines := metrics.NewScrapeDeadlines()
+	opts = append(opts, metrics.WithScrapeDeadlines(deadlines))
+
+	if discoverer.Enabled() {
+		opts = append(opts, metrics.WithTargetsFunc(discoverer.Targets))
+	}
+
+	exporterConfig, err := exporterConfig()
+	if err != nil {
+		return fmt.Errorf("error configuring metric exporters: %w", err)
+	}
+
+	runHTTP, shutdownHTTP, err := newServer(clientOpts, deadlines, discoverer.Targets)
+	if err != nil {
+		return err
+	}
+
+	shutdownExporters, err := metrics.SetupExporters(ctx, exporterConfig)
+	if err != nil {
+		return fmt.Errorf("error setting up metric exporters: %w", err)
+	}
+
+	manager := lifecycle.New(viper.GetDuration("shutdown_timeout"))
+
+	// The exporters are shut down after the server, so they flush the metrics of the final scrapes.
+	// The forecast state is flushed last, after the final scrapes of the server and exporters.
+	if forecaster != nil {
+		manager.Add("forecast state", nil, func(context.Context) error {
+			return forecaster.Save() //nolint:wrapcheck // already wrapped by the forecaster
+		})
+	}
+
+	manager.Add("metric exporters", nil, lifecycle.ShutdownFunc(shutdownExporters))
+
+	if err := metrics.RegisterStorageMetrics(targets, opts...); err != nil {
+		return errors.Join(fmt.Errorf("error registering storage metrics: %w", err), manager.Shutdown(ctx))
+	}
+
+	if err := metrics.RegisterClientMetrics(limiters, targets, opts...); err != nil {
+		return errors.Join(fmt.Errorf("error registering client metrics: %w", err), manager.Shutdown(ctx))
+	}
+
+	if discoverer.Enabled() {
+		manager.Add("target discovery", discoverer.Run, discoverer.Shutdown)
+	}
+
+	manager.Add("metrics server", runHTTP, shutdownHTTP)
+
+	return manager.Run(ctx) //nolint:wrapcheck // already wrapped by the manager
+}
+
+// NewServerCommand creates a new server command for the zadara-exporter.
+func NewServerCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "server",
+		Short:        "Start the Zadara exporter server",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runServer(cmd.Context())
 		},
 	}
 
@@ -109,16 +294,37 @@ func NewServerCommand() *cobra.Command {
 	viper.SetDefault("listen_path", metrics.DefaultPath)
 	viper.SetDefault("health_path", health.DefaultPath)
 	viper.SetDefault("namespace", metrics.DefaultNamespace)
+	viper.SetDefault("shutdown_timeout", lifecycle.DefaultGracePeriod)
+	viper.SetDefault("scrape_timeout_offset", metrics.DefaultScrapeTimeoutOffset)
+	viper.SetDefault("legacy_metric_names", false)
+	viper.SetDefault("verify_tokens", true)
+	viper.SetDefault("forecast.enabled", false)
+	viper.SetDefault("prometheus.enabled", true)
+	viper.SetDefault("otlp.enabled", false)
+	viper.SetDefault("remote_write.enabled", false)
+	viper.SetDefault("rate_limit.requests_per_second", 0)
+	viper.SetDefault("coalesce.enabled", true)
+	viper.SetDefault("coalesce.cache_ttl", 0)
+	viper.SetDefault("coalesce.timeout", commandcenter.DefaultCoalesceTimeout)
+	viper.SetDefault("circuit_breaker.failure_threshold", commandcenter.DefaultBreakerConfig.FailureThreshold)
+	viper.SetDefault("circuit_breaker.open_timeout", commandcenter.DefaultBreakerConfig.OpenTimeout)
+	viper.SetDefault("circuit_breaker.half_open_requests", commandcenter.DefaultBreakerConfig.HalfOpenRequests)
 
 	cmd.Flags().String("listen_address", ":9090", "The address to listen on for the metrics server")
 	cmd.Flags().String("listen_path", metrics.DefaultPath, "The path to expose the metrics on")
 	cmd.Flags().String("health_path", health.DefaultPath, "The path to expose the health check on")
 	cmd.Flags().String("namespace", metrics.DefaultNamespace, "The namespace to use for the metrics")
+	cmd.Flags().Duration("shutdown_timeout", lifecycle.DefaultGracePeriod,
+		"How long to wait for in-flight scrapes and background collectors to finish when shutting down")
+	cmd.Flags().Duration("scrape_timeout_offset", metrics.DefaultScrapeTimeoutOffset,
+		"How long before the Prometheus scrape timeout to stop collecting the metrics")
 
 	must(viper.BindPFlag("listen_address", cmd.Flags().Lookup("listen_address")))
 	must(viper.BindPFlag("listen_path", cmd.Flags().Lookup("listen_path")))
 	must(viper.BindPFlag("health_path", cmd.Flags().Lookup("health_path")))
 	must(viper.BindPFlag("namespace", cmd.Flags().Lookup("namespace")))
+	must(viper.BindPFlag("shutdown_timeout", cmd.Flags().Lookup("shutdown_timeout")))
+	must(viper.BindPFlag("scrape_timeout_offset", cmd.Flags().Lookup("scrape_timeout_offset")))
 
 	return cmd
 }
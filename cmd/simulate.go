@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/krystal/zadara-exporter/lifecycle"
+	"github.com/krystal/zadara-exporter/simulator"
+	"github.com/spf13/cobra"
+)
+
+// loadFixture loads the fixture from the path, or the default fixture if the path is empty,
+// and applies the flags that were set.
+func loadFixture(cmd *cobra.Command, path string) (*simulator.Fixture, error) {
+	fixture := simulator.DefaultFixture()
+
+	if path != "" {
+		var err error
+
+		fixture, err = simulator.LoadFixture(path)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // already wrapped by simulator
+		}
+	}
+
+	flags := cmd.Flags()
+
+	if flags.Changed("latency") {
+		fixture.Latency, _ = flags.GetDuration("latency")
+	}
+
+	if flags.Changed("error-rate") {
+		fixture.ErrorRate, _ = flags.GetFloat64("error-rate")
+	}
+
+	if flags.Changed("token") {
+		fixture.Token, _ = flags.GetString("token")
+	}
+
+	return fixture, nil
+}
+
+// NewSimulateCommand creates a new simulate command for the zadara-exporter.
+func NewSimulateCommand() *cobra.Command {
+	var fixturePath, listenAddress string
+
+	cmd := &cobra.Command{
+		Use:          "simulate",
+		Short:        "Serve a fake Zadara Command Center API for development and testing",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			fixture, err := loadFixture(cmd, fixturePath)
+			if err != nil {
+				return err
+			}
+
+			const ReadHeaderTimeout = 10 * time.Second
+
+			server := &http.Server{
+				Addr:              listenAddress,
+				Handler:           simulator.New(fixture),
+				ReadHeaderTimeout: ReadHeaderTimeout,
+			}
+
+			manager := lifecycle.New(0)
+			manager.Add("simulator", func() error {
+				slog.Info("starting Command Center simulator",
+					"address", listenAddress,
+					"clouds", len(fixture.Clouds),
+					"latency", fixture.Latency,
+					"error_rate", fixture.ErrorRate)
+
+				err := server.ListenAndServe()
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					return fmt.Errorf("error starting HTTP server: %w", err)
+				}
+
+				return nil
+			}, func(ctx context.Context) error {
+				if err := server.Shutdown(ctx); err != nil {
+					return fmt.Errorf("error shutting down HTTP server: %w", err)
+				}
+
+				return nil
+			})
+
+			return manager.Run(cmd.Context()) //nolint:wrapcheck // already wrapped by the manager
+		},
+	}
+
+	cmd.Flags().StringVarP(&fixturePath, "fixture", "f", "",
+		"The YAML fixture to serve, or the built in fixture if not set")
+	cmd.Flags().StringVar(&listenAddress, "listen_address", ":8888", "The address to listen on")
+	cmd.Flags().Duration("latency", 0, "The latency added to every response, overriding the fixture")
+	cmd.Flags().Float64("error-rate", 0, "The ratio of requests that fail, overriding the fixture")
+	cmd.Flags().String("token", "", "The X-Token requests must have, overriding the fixture")
+
+	return cmd
+}
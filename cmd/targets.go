@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/discovery"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/spf13/viper"
+)
+
+// tokenVerificationTimeout is how long the tokens of the targets are verified for at startup.
+const tokenVerificationTimeout = 30 * time.Second
+
+// newDiscoverer returns the discoverer of the targets, starting with the static targets.
+func newDiscoverer(targets []*config.Target) (*discovery.Discoverer, error) {
+	var discoveryConfig discovery.Config
+	if err := viper.UnmarshalKey("discovery", &discoveryConfig); err != nil {
+		return nil, fmt.Errorf("could not unmarshal discovery config: %w", err)
+	}
+
+	return discovery.New(targets, discoveryConfig), nil
+}
+
+// loadTargets returns the static targets of the configuration and the targets discovered once.
+func loadTargets(ctx context.Context) ([]*config.Target, error) {
+	targets, err := config.GetTargets()
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling targets: %w", err)
+	}
+
+	discoverer, err := newDiscoverer(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := discoverer.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("error discovering targets: %w", err)
+	}
+
+	return discoverer.Targets(), nil
+}
+
+// verifyTokens checks the Command Center of each target accepts its token, logging the targets whose token was
+// rejected apart from those that could not be reached, so revoked or expired tokens are noticed at startup.
+func verifyTokens(ctx context.Context, targets []*config.Target, opts []commandcenter.Option) {
+	ctx, cancel := context.WithTimeout(ctx, tokenVerificationTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	for _, target := range targets {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			client := commandcenter.NewClient(target, opts...)
+			err := client.VerifyToken(ctx, target)
+			fingerprint := client.TokenFingerprint(ctx)
+
+			switch {
+			case err == nil:
+				slog.Info("verified token", "name", target.Name, "token_fingerprint", fingerprint)
+			case vpsaobjectstorage.IsUnauthorized(err):
+				slog.Error("token rejected",
+					"name", target.Name, "url", target.URL, "token_fingerprint", fingerprint, "error", err)
+			default:
+				slog.Warn("could not verify token",
+					"name", target.Name, "url", target.URL, "token_fingerprint", fingerprint, "error", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
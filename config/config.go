@@ -4,8 +4,11 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 type (
@@ -15,16 +18,72 @@ type (
 		CloudName string `mapstructure:"cloud_name"`
 		Name      string `mapstructure:"name"`
 		Token     string `mapstructure:"token"`
+
+		// TokenFile is the file the token is read from, in place of Token.
+		TokenFile string `mapstructure:"token_file"`
+
+		// Labels are static labels added to all the metrics of the target.
+		Labels map[string]string `mapstructure:"labels"`
+
+		// DiscoverClouds configures the discovery of the clouds of the Command Center, in place of CloudName.
+		DiscoverClouds CloudDiscovery `mapstructure:"discover_clouds"`
+	}
+
+	// CloudDiscovery configures the discovery of the clouds of a Command Center.
+	CloudDiscovery struct {
+		// Enabled is whether the metrics of all the clouds of the Command Center are collected.
+		Enabled bool `mapstructure:"enabled"`
+
+		// Include is the regular expressions matching the names of the clouds to collect.
+		// All clouds are included if empty.
+		Include []string `mapstructure:"include"`
+
+		// Exclude is the regular expressions matching the names of the clouds not to collect.
+		Exclude []string `mapstructure:"exclude"`
+	}
+
+	// UppercaseLabel represents a static label of a target in the config file whose name has upper case letters.
+	UppercaseLabel struct {
+		Target string
+		Label  string
 	}
 )
 
-// GetTargets returns the list of targets from the configuration.
+// ForCloud returns a copy of the target for one of its discovered clouds.
+func (t *Target) ForCloud(cloudName string) *Target {
+	cloudTarget := *t
+	cloudTarget.CloudName = cloudName
+	cloudTarget.DiscoverClouds = CloudDiscovery{}
+
+	return &cloudTarget
+}
+
+// GetTargets returns the list of targets from the configuration file, overridden and extended by the indexed
+// environment variables and then the target flags, with the tokens of their token files.
 func GetTargets() ([]*Target, error) {
 	var targets []*Target
 	if err := viper.UnmarshalKey("targets", &targets); err != nil {
 		return nil, fmt.Errorf("could not unmarshal targets: %w", err)
 	}
 
+	targets, err := ApplyEnv(targets, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, flag := range viper.GetStringSlice("target") {
+		target, err := ParseTarget(flag)
+		if err != nil {
+			return nil, err
+		}
+
+		targets = MergeTarget(targets, target)
+	}
+
+	if err := ReadTokenFiles(targets); err != nil {
+		return nil, err
+	}
+
 	return targets, nil
 }
 
@@ -54,7 +113,56 @@ func Setup() error {
 
 	if err := viper.ReadInConfig(); err != nil {
 		slog.Warn("Could not read config file", "error", err)
+
+		return nil
 	}
 
+	warnUppercaseLabels(viper.ConfigFileUsed())
+
 	return nil
 }
+
+// UppercaseLabels returns the static labels of the targets in the YAML config whose names have upper case letters.
+// They are exported in lower case, as viper reads map keys in lower case.
+func UppercaseLabels(data []byte) ([]UppercaseLabel, error) {
+	var raw struct {
+		Targets []struct {
+			Name   string            `yaml:"name"`
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"targets"`
+	}
+
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error decoding config file: %w", err)
+	}
+
+	var labels []UppercaseLabel
+
+	for _, target := range raw.Targets {
+		for name := range target.Labels {
+			if name != strings.ToLower(name) {
+				labels = append(labels, UppercaseLabel{Target: target.Name, Label: name})
+			}
+		}
+	}
+
+	return labels, nil
+}
+
+// warnUppercaseLabels warns about the static labels in the config file whose names have upper case letters.
+func warnUppercaseLabels(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	labels, err := UppercaseLabels(data)
+	if err != nil {
+		return
+	}
+
+	for _, label := range labels {
+		slog.Warn("label names are read in lower case from the config file",
+			"target", label.Target, "label", label.Label, "exported_as", strings.ToLower(label.Label))
+	}
+}
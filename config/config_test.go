@@ -0,0 +1,27 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUppercaseLabels(t *testing.T) {
+	t.Parallel()
+
+	labels, err := config.UppercaseLabels([]byte(`
+targets:
+  - name: London
+    labels:
+      Region: eu-west
+      environment: production
+  - name: Paris
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []config.UppercaseLabel{{Target: "London", Label: "Region"}}, labels)
+
+	_, err = config.UppercaseLabels([]byte("targets: {"))
+	require.Error(t, err)
+}
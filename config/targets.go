@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EnvTargetPrefix is the prefix of the indexed environment variables of the targets,
+// such as ZADARA_TARGETS_0_URL.
+const EnvTargetPrefix = "ZADARA_TARGETS_"
+
+var (
+	// ErrInvalidTarget is returned when a target from the flags or environment is malformed or incomplete,
+	// or when a target sets both a token and a token file.
+	ErrInvalidTarget = errors.New("invalid target")
+
+	// ErrUnknownTargetField is returned when a target from the flags or environment sets an unknown field.
+	ErrUnknownTargetField = errors.New("unknown target field")
+
+	// envTargetPattern matches the indexed environment variables of the targets, capturing the index and field.
+	envTargetPattern = regexp.MustCompile("^" + EnvTargetPrefix + `(\d+)_([A-Z_]+)$`)
+)
+
+// setField sets the field of the target with the name of its configuration key.
+func (t *Target) setField(key, value string) error {
+	switch key {
+	case "name":
+		t.Name = value
+	case "url":
+		t.URL = value
+	case "cloud_name":
+		t.CloudName = value
+	case "token":
+		t.Token = value
+	case "token_file":
+		t.TokenFile = value
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownTargetField, key)
+	}
+
+	return nil
+}
+
+// ParseTarget parses a target from a comma separated list of key=value pairs, such as
+// name=London,url=https://cc.example.com,cloud_name=cc1,token_file=/run/secrets/token.
+// The name and URL are required.
+func ParseTarget(s string) (*Target, error) {
+	target := &Target{}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not a key=value pair", ErrInvalidTarget, pair)
+		}
+
+		if err := target.setField(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return nil, err
+		}
+	}
+
+	if target.Name == "" || target.URL == "" {
+		return nil, fmt.Errorf("%w: %q must have a name and url", ErrInvalidTarget, s)
+	}
+
+	return target, nil
+}
+
+// ApplyEnv applies the indexed environment variables of the targets, such as ZADARA_TARGETS_0_URL, to the targets.
+// Indexes of existing targets override their fields, and the other indexes add targets in index order,
+// which must have a name and URL.
+func ApplyEnv(targets []*Target, environ []string) ([]*Target, error) {
+	fields := map[int]map[string]string{}
+
+	for _, env := range environ {
+		key, value, _ := strings.Cut(env, "=")
+
+		match := envTargetPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidTarget, key, err)
+		}
+
+		if fields[index] == nil {
+			fields[index] = map[string]string{}
+		}
+
+		fields[index][strings.ToLower(match[2])] = value
+	}
+
+	indexes := make([]int, 0, len(fields))
+	for index := range fields {
+		indexes = append(indexes, index)
+	}
+
+	slices.Sort(indexes)
+
+	existing := len(targets)
+
+	for _, index := range indexes {
+		target := &Target{}
+		added := index >= existing
+
+		if !added {
+			target = targets[index]
+		}
+
+		for key, value := range fields[index] {
+			if err := target.setField(key, value); err != nil {
+				return nil, fmt.Errorf("error in %s%d: %w", EnvTargetPrefix, index, err)
+			}
+		}
+
+		if !added {
+			continue
+		}
+
+		if target.Name == "" || target.URL == "" {
+			return nil, fmt.Errorf("%w: %s%d must have a name and url", ErrInvalidTarget, EnvTargetPrefix, index)
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// MergeTarget returns the targets with the target replacing the target with the same name, or added otherwise.
+func MergeTarget(targets []*Target, target *Target) []*Target {
+	for i, existing := range targets {
+		if existing.Name == target.Name {
+			targets[i] = target
+
+			return targets
+		}
+	}
+
+	return append(targets, target)
+}
+
+// ReadTokenFiles sets the tokens of the targets with a token file to the contents of the file,
+// without surrounding whitespace. Targets setting both a token and a token file are rejected.
+func ReadTokenFiles(targets []*Target) error {
+	for _, target := range targets {
+		if target.TokenFile == "" {
+			continue
+		}
+
+		if target.Token != "" {
+			return fmt.Errorf("%w: %s must not set both token and token_file", ErrInvalidTarget, target.Name)
+		}
+
+		token, err := os.ReadFile(target.TokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading token file of %s: %w", target.Name, err)
+		}
+
+		target.Token = strings.TrimSpace(string(token))
+	}
+
+	return nil
+}
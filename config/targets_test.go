@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTarget(t *testing.T) {
+	t.Parallel()
+
+	target, err := config.ParseTarget("name=London, url=https://cc1.example.com,cloud_name=cc1,token_file=/run/token")
+	require.NoError(t, err)
+	assert.Equal(t, &config.Target{
+		Name:      "London",
+		URL:       "https://cc1.example.com",
+		CloudName: "cc1",
+		TokenFile: "/run/token",
+	}, target)
+
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{name: "not a pair", input: "name=London,url", err: config.ErrInvalidTarget},
+		{name: "unknown field", input: "name=London,region=eu", err: config.ErrUnknownTargetField},
+		{name: "no url", input: "name=London,cloud_name=cc1", err: config.ErrInvalidTarget},
+	}
+
+	for _, tt := range tests {
+		_, err := config.ParseTarget(tt.input)
+		require.ErrorIs(t, err, tt.err, tt.name)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Parallel()
+
+	targets := []*config.Target{{Name: "London", URL: "https://cc1.example.com", CloudName: "cc1", Token: "file"}}
+
+	targets, err := config.ApplyEnv(targets, []string{
+		"ZADARA_TARGETS_0_TOKEN=env",
+		"ZADARA_TARGETS_5_NAME=Paris",
+		"ZADARA_TARGETS_5_URL=https://cc3.example.com",
+		"ZADARA_TARGETS_2_NAME=New York",
+		"ZADARA_TARGETS_2_URL=https://cc2.example.com",
+		"ZADARA_TARGETS_2_CLOUD_NAME=cc2",
+		"ZADARA_LISTEN_ADDRESS=:9090",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*config.Target{
+		{Name: "London", URL: "https://cc1.example.com", CloudName: "cc1", Token: "env"},
+		{Name: "New York", URL: "https://cc2.example.com", CloudName: "cc2"},
+		{Name: "Paris", URL: "https://cc3.example.com"},
+	}, targets)
+
+	_, err = config.ApplyEnv(nil, []string{"ZADARA_TARGETS_0_NAME=London"})
+	require.ErrorIs(t, err, config.ErrInvalidTarget)
+
+	_, err = config.ApplyEnv(nil, []string{"ZADARA_TARGETS_0_REGION=eu"})
+	require.ErrorIs(t, err, config.ErrUnknownTargetField)
+}
+
+func TestMergeTarget(t *testing.T) {
+	t.Parallel()
+
+	targets := []*config.Target{{Name: "London", URL: "https://cc1.example.com"}}
+
+	targets = config.MergeTarget(targets, &config.Target{Name: "London", URL: "https://flag.example.com"})
+	targets = config.MergeTarget(targets, &config.Target{Name: "Paris", URL: "https://cc3.example.com"})
+
+	assert.Equal(t, []*config.Target{
+		{Name: "London", URL: "https://flag.example.com"},
+		{Name: "Paris", URL: "https://cc3.example.com"},
+	}, targets)
+}
+
+func TestReadTokenFiles(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("secret\n"), 0o600))
+
+	targets := []*config.Target{
+		{Name: "London", Token: "inline"},
+		{Name: "Paris", TokenFile: path},
+	}
+
+	require.NoError(t, config.ReadTokenFiles(targets))
+	assert.Equal(t, "inline", targets[0].Token)
+	assert.Equal(t, "secret", targets[1].Token)
+
+	err := config.ReadTokenFiles([]*config.Target{{Name: "New York", TokenFile: path + ".missing"}})
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = config.ReadTokenFiles([]*config.Target{{Name: "Tokyo", Token: "inline", TokenFile: path}})
+	require.ErrorIs(t, err, config.ErrInvalidTarget)
+	assert.ErrorContains(t, err, "Tokyo must not set both token and token_file")
+}
@@ -0,0 +1,444 @@
+// Package dashboard generates a Grafana dashboard for the zadara-exporter metrics.
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/krystal/zadara-exporter/metrics"
+)
+
+type (
+	// Dashboard represents a Grafana dashboard.
+	Dashboard struct {
+		UID           string      `json:"uid,omitempty"`
+		Title         string      `json:"title"`
+		Description   string      `json:"description,omitempty"`
+		Tags          []string    `json:"tags"`
+		Timezone      string      `json:"timezone"`
+		Editable      bool        `json:"editable"`
+		SchemaVersion int         `json:"schemaVersion"`
+		Refresh       string      `json:"refresh"`
+		Time          TimeRange   `json:"time"`
+		Templating    Templating  `json:"templating"`
+		Panels        []*Panel    `json:"panels"`
+		Annotations   Annotations `json:"annotations"`
+	}
+
+	// TimeRange represents the default time range of a dashboard.
+	TimeRange struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+
+	// Annotations represents the annotations of a dashboard.
+	Annotations struct {
+		List []any `json:"list"`
+	}
+
+	// Templating represents the templating variables of a dashboard.
+	Templating struct {
+		List []*Variable `json:"list"`
+	}
+
+	// Variable represents a templating variable.
+	Variable struct {
+		Name       string         `json:"name"`
+		Label      string         `json:"label"`
+		Type       string         `json:"type"`
+		Query      string         `json:"query"`
+		Definition string         `json:"definition,omitempty"`
+		Datasource *DataSourceRef `json:"datasource,omitempty"`
+		Refresh    int            `json:"refresh,omitempty"`
+		Sort       int            `json:"sort,omitempty"`
+		IncludeAll bool           `json:"includeAll"`
+		Multi      bool           `json:"multi"`
+		AllValue   string         `json:"allValue,omitempty"`
+	}
+
+	// DataSourceRef represents a reference to a Grafana data source.
+	DataSourceRef struct {
+		Type string `json:"type"`
+		UID  string `json:"uid"`
+	}
+
+	// Panel represents a dashboard panel or row.
+	Panel struct {
+		ID          int            `json:"id"`
+		Type        string         `json:"type"`
+		Title       string         `json:"title"`
+		Description string         `json:"description,omitempty"`
+		GridPos     GridPos        `json:"gridPos"`
+		Datasource  *DataSourceRef `json:"datasource,omitempty"`
+		Targets     []*Target      `json:"targets,omitempty"`
+		FieldConfig *FieldConfig   `json:"fieldConfig,omitempty"`
+		Collapsed   bool           `json:"collapsed,omitempty"`
+		Panels      []*Panel       `json:"panels,omitempty"`
+	}
+
+	// GridPos represents the position of a panel on the dashboard grid.
+	GridPos struct {
+		H int `json:"h"`
+		W int `json:"w"`
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+
+	// Target represents a panel query.
+	Target struct {
+		RefID        string         `json:"refId"`
+		Datasource   *DataSourceRef `json:"datasource,omitempty"`
+		Expr         string         `json:"expr"`
+		LegendFormat string         `json:"legendFormat,omitempty"`
+	}
+
+	// FieldConfig represents the field configuration of a panel.
+	FieldConfig struct {
+		Defaults  FieldDefaults `json:"defaults"`
+		Overrides []any         `json:"overrides"`
+	}
+
+	// FieldDefaults represents the default field configuration of a panel.
+	FieldDefaults struct {
+		Unit string `json:"unit,omitempty"`
+	}
+
+	// Config represents the configuration used to generate the dashboard.
+	Config struct {
+		// Namespace is the namespace the metrics are exported with.
+		Namespace string
+
+		// Title is the title of the dashboard.
+		Title string
+
+		// UID is the unique identifier of the dashboard.
+		UID string
+
+		// Labels is the configuration of the built-in labels the metrics are exported with.
+		Labels metrics.LabelConfig
+	}
+
+	// label represents a built-in label the dashboard is filtered by.
+	label struct {
+		name  string
+		title string
+	}
+
+	// builder lays out panels on the dashboard grid.
+	builder struct {
+		config Config
+		panels []*Panel
+		nextID int
+		x, y   int
+	}
+)
+
+const (
+	// DefaultTitle is the default title of the dashboard.
+	DefaultTitle = "Zadara Storage"
+
+	// DefaultUID is the default unique identifier of the dashboard.
+	DefaultUID = "zadara-exporter"
+
+	schemaVersion = 39
+	gridWidth     = 24
+	panelWidth    = 8
+	panelHeight   = 8
+	rowHeight     = 1
+
+	// refreshOnTimeRangeChange refreshes the variable values when the time range changes.
+	refreshOnTimeRangeChange = 2
+
+	// sortAlphabetical sorts the variable values alphabetically.
+	sortAlphabetical = 1
+)
+
+// filterLabels returns the built-in labels the dashboard is filtered by, in the order they narrow down the metrics.
+func filterLabels() []label {
+	return []label{
+		{metrics.NameLabel, "Name"},
+		{metrics.CloudNameLabel, "Cloud"},
+		{metrics.StoreLabel, "Store"},
+		{metrics.PolicyNameLabel, "Policy"},
+	}
+}
+
+// Generate returns the dashboard for the given configuration.
+// If the namespace, title or UID are empty, their defaults are used.
+func Generate(config Config) *Dashboard {
+	if config.Namespace == "" {
+		config.Namespace = metrics.DefaultNamespace
+	}
+
+	if config.Title == "" {
+		config.Title = DefaultTitle
+	}
+
+	if config.UID == "" {
+		config.UID = DefaultUID
+	}
+
+	b := &builder{config: config, nextID: 1}
+
+	b.row("Capacity")
+	b.utilisationPanel()
+
+	var group string
+
+	for _, def := range metrics.Definitions() {
+		if def.Group != group {
+			group = def.Group
+			b.row(groupTitle(group))
+		}
+
+		b.metricPanel(def)
+	}
+
+	return &Dashboard{
+		UID:           config.UID,
+		Title:         config.Title,
+		Description:   "Capacity, ring balance and health of Zadara object storage, from the zadara-exporter.",
+		Tags:          []string{"zadara", "storage"},
+		Timezone:      "browser",
+		Editable:      true,
+		SchemaVersion: schemaVersion,
+		Refresh:       "1m",
+		Time:          TimeRange{From: "now-24h", To: "now"},
+		Templating:    Templating{List: variables(config)},
+		Panels:        b.panels,
+		Annotations:   Annotations{List: []any{}},
+	}
+}
+
+// Write encodes the dashboard as indented JSON to the writer.
+func Write(w io.Writer, dashboard *Dashboard) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(dashboard); err != nil {
+		return fmt.Errorf("error encoding dashboard: %w", err)
+	}
+
+	return nil
+}
+
+func datasource() *DataSourceRef {
+	return &DataSourceRef{Type: "prometheus", UID: "${datasource}"}
+}
+
+// variables returns the templating variables, each filtered by the ones before it.
+func variables(config Config) []*Variable {
+	metric := metrics.PrometheusName(config.Namespace, metrics.FreeStorageName)
+
+	vars := []*Variable{
+		{
+			Name:  "datasource",
+			Label: "Data source",
+			Type:  "datasource",
+			Query: "prometheus",
+		},
+	}
+
+	var matchers []string
+
+	for _, l := range filterLabels() {
+		name, ok := config.Labels.LabelName(l.name)
+		if !ok {
+			continue
+		}
+
+		series := metric
+		if len(matchers) > 0 {
+			series += "{" + strings.Join(matchers, ", ") + "}"
+		}
+
+		query := fmt.Sprintf("label_values(%s, %s)", series, name)
+
+		vars = append(vars, &Variable{
+			Name:       name,
+			Label:      l.title,
+			Type:       "query",
+			Query:      query,
+			Definition: query,
+			Datasource: datasource(),
+			Refresh:    refreshOnTimeRangeChange,
+			Sort:       sortAlphabetical,
+			IncludeAll: true,
+			Multi:      true,
+			AllValue:   ".*",
+		})
+
+		matchers = append(matchers, matcher(name))
+	}
+
+	return vars
+}
+
+// matcher returns the label matcher filtering the label by the values of its templating variable.
+func matcher(name string) string {
+	return fmt.Sprintf(`%s=~"$%s"`, name, name)
+}
+
+// selector returns the label selector for metrics observed at the given level.
+func (b *builder) selector(level metrics.Level) string {
+	var matchers []string
+
+	for _, l := range filterLabels() {
+		if l.name == metrics.PolicyNameLabel && level != metrics.PolicyLevel {
+			continue
+		}
+
+		if name, ok := b.config.Labels.LabelName(l.name); ok {
+			matchers = append(matchers, matcher(name))
+		}
+	}
+
+	if len(matchers) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// legend returns the legend format for metrics observed at the given level.
+func (b *builder) legend(level metrics.Level) string {
+	names := []string{metrics.StoreLabel}
+	if level == metrics.PolicyLevel {
+		names = append(names, metrics.PolicyNameLabel)
+	}
+
+	var parts []string
+	for _, name := range b.config.Labels.LabelNames(names...) {
+		parts = append(parts, "{{"+name+"}}")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// row starts a new row on the dashboard.
+func (b *builder) row(title string) {
+	if b.x > 0 {
+		b.y += panelHeight
+		b.x = 0
+	}
+
+	b.panels = append(b.panels, &Panel{
+		ID:      b.id(),
+		Type:    "row",
+		Title:   title,
+		GridPos: GridPos{H: rowHeight, W: gridWidth, X: 0, Y: b.y},
+	})
+
+	b.y += rowHeight
+}
+
+// panel adds a time series panel to the current row.
+func (b *builder) panel(title, description, unit string, targets ...*Target) {
+	if b.x+panelWidth > gridWidth {
+		b.y += panelHeight
+		b.x = 0
+	}
+
+	for i, target := range targets {
+		target.RefID = string(rune('A' + i))
+		target.Datasource = datasource()
+	}
+
+	b.panels = append(b.panels, &Panel{
+		ID:          b.id(),
+		Type:        "timeseries",
+		Title:       title,
+		Description: description,
+		GridPos:     GridPos{H: panelHeight, W: panelWidth, X: b.x, Y: b.y},
+		Datasource:  datasource(),
+		Targets:     targets,
+		FieldConfig: &FieldConfig{Defaults: FieldDefaults{Unit: unit}, Overrides: []any{}},
+	})
+
+	b.x += panelWidth
+}
+
+func (b *builder) id() int {
+	id := b.nextID
+	b.nextID++
+
+	return id
+}
+
+// utilisationPanel adds a panel showing the used storage as a ratio of the total storage of each policy.
+func (b *builder) utilisationPanel() {
+	sel := b.selector(metrics.PolicyLevel)
+	used := metrics.PrometheusName(b.config.Namespace, metrics.UsedStorageName) + sel
+	free := metrics.PrometheusName(b.config.Namespace, metrics.FreeStorageName) + sel
+
+	b.panel("Storage utilisation",
+		"The used storage as a ratio of the total storage in the Zadara store storage policy.",
+		"percentunit",
+		&Target{
+			Expr:         fmt.Sprintf("%s / (%s + %s)", used, used, free),
+			LegendFormat: b.legend(metrics.PolicyLevel),
+		})
+}
+
+// metricPanel adds a panel showing the metric.
+func (b *builder) metricPanel(def metrics.Definition) {
+	expr := metrics.PrometheusName(b.config.Namespace, def.Name) + b.selector(def.Level)
+	unit := grafanaUnit(def.Unit)
+
+	// Grafana expects timestamps in milliseconds.
+	if strings.HasSuffix(def.Name, "_timestamp_seconds") {
+		expr += " * 1000"
+		unit = "dateTimeFromNow"
+	}
+
+	b.panel(panelTitle(def.Name), def.Description, unit, &Target{
+		Expr:         expr,
+		LegendFormat: b.legend(def.Level),
+	})
+}
+
+// grafanaUnit returns the Grafana unit for the UCUM unit.
+func grafanaUnit(unit string) string {
+	switch unit {
+	case "By", "By/d":
+		return "bytes"
+	case "%":
+		return "percent"
+	case "1":
+		return "percentunit"
+	case "s":
+		return "s"
+	default:
+		return "short"
+	}
+}
+
+// panelTitle returns a human readable title for the metric name, without its bytes or seconds suffix
+// as the panel shows the unit. Ratios keep their suffix to tell them apart from the ring balance counts.
+func panelTitle(name string) string {
+	for _, suffix := range []string{"_bytes", "_seconds"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+
+	title := strings.ToLower(strings.ReplaceAll(name, "_", " "))
+
+	return strings.ToUpper(title[:1]) + title[1:]
+}
+
+// groupTitle returns the title of the row for the group of metrics.
+func groupTitle(group string) string {
+	switch group {
+	case metrics.StoreGroup:
+		return "Store"
+	case metrics.PolicyGroup:
+		return "Storage Policy"
+	case metrics.RingBalanceGroup:
+		return "Ring Balance"
+	case metrics.ForecastGroup:
+		return "Forecast"
+	default:
+		return panelTitle(group)
+	}
+}
@@ -0,0 +1,92 @@
+package dashboard_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/dashboard"
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	d := dashboard.Generate(dashboard.Config{Namespace: "storage"})
+
+	assert.Equal(t, dashboard.DefaultTitle, d.Title)
+	assert.Equal(t, dashboard.DefaultUID, d.UID)
+
+	variables := map[string]string{}
+	for _, v := range d.Templating.List {
+		variables[v.Name] = v.Query
+	}
+
+	assert.Equal(t, "label_values(storage_free_storage_bytes, name)", variables["name"])
+	assert.Equal(t,
+		`label_values(storage_free_storage_bytes{name=~"$name", cloud_name=~"$cloud_name", store=~"$store"}, policy_name)`,
+		variables["policy_name"])
+
+	exprs := map[string]string{}
+	ids := map[int]bool{}
+
+	for _, panel := range d.Panels {
+		assert.False(t, ids[panel.ID], "duplicate panel id %d", panel.ID)
+		ids[panel.ID] = true
+
+		for _, target := range panel.Targets {
+			exprs[panel.Title] = target.Expr
+		}
+	}
+
+	// Every metric definition has a panel.
+	assert.Len(t, exprs, len(metrics.Definitions())+1)
+	assert.Equal(t, `storage_accounts{name=~"$name", cloud_name=~"$cloud_name", store=~"$store"}`,
+		exprs["Accounts"])
+	assert.Equal(t,
+		`storage_free_storage_bytes{name=~"$name", cloud_name=~"$cloud_name", store=~"$store", policy_name=~"$policy_name"}`,
+		exprs["Free storage"])
+}
+
+func TestGenerate_Labels(t *testing.T) {
+	t.Parallel()
+
+	d := dashboard.Generate(dashboard.Config{
+		Labels: metrics.LabelConfig{
+			Drop:   []string{metrics.CloudNameLabel},
+			Rename: map[string]string{metrics.NameLabel: "target"},
+		},
+	})
+
+	variables := map[string]string{}
+	for _, v := range d.Templating.List {
+		variables[v.Name] = v.Query
+	}
+
+	assert.NotContains(t, variables, "name")
+	assert.NotContains(t, variables, "cloud_name")
+	assert.Equal(t, `label_values(zadara_free_storage_bytes{target=~"$target"}, store)`, variables["store"])
+
+	for _, panel := range d.Panels {
+		if panel.Title == "Accounts" {
+			require.Len(t, panel.Targets, 1)
+			assert.Equal(t, `zadara_accounts{target=~"$target", store=~"$store"}`, panel.Targets[0].Expr)
+			assert.Equal(t, "{{store}}", panel.Targets[0].LegendFormat)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	require.NoError(t, dashboard.Write(&buf, dashboard.Generate(dashboard.Config{})))
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "Zadara Storage", decoded["title"])
+	assert.NotEmpty(t, decoded["panels"])
+}
@@ -0,0 +1,252 @@
+// Package discovery loads the targets of the exporter from a directory of files and from an HTTP service
+// discovery endpoint, in addition to the static targets of the configuration.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/secrets"
+	"github.com/mitchellh/mapstructure"
+)
+
+type (
+	// Config configures the discovery of targets.
+	Config struct {
+		// Files configures the discovery of targets from a directory of files.
+		Files FilesConfig `mapstructure:"files"`
+
+		// HTTP configures the discovery of targets from an HTTP endpoint.
+		HTTP HTTPConfig `mapstructure:"http"`
+	}
+
+	// Discoverer merges the static targets with the targets discovered from files and HTTP.
+	Discoverer struct {
+		static []*config.Target
+		files  *fileSource
+		http   *httpSource
+
+		mu         sync.RWMutex
+		targets    []*config.Target
+		duplicates map[string]bool
+
+		ctx    context.Context //nolint:containedctx // cancelled by Shutdown to stop Run
+		cancel context.CancelFunc
+		done   chan struct{}
+	}
+)
+
+// ErrInvalidTarget is returned when a discovered target has no name or URL, or its token references a secret.
+var ErrInvalidTarget = errors.New("invalid target")
+
+// New returns a discoverer of the targets with the configuration, starting with the static targets.
+func New(static []*config.Target, cfg Config) *Discoverer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &Discoverer{
+		static: static,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if cfg.Files.Directory != "" {
+		d.files = newFileSource(cfg.Files)
+	}
+
+	if cfg.HTTP.URL != "" {
+		d.http = newHTTPSource(cfg.HTTP, http.DefaultClient)
+	}
+
+	d.merge()
+
+	return d
+}
+
+// Enabled returns whether any targets are discovered in addition to the static targets.
+func (d *Discoverer) Enabled() bool {
+	return d.files != nil || d.http != nil
+}
+
+// Targets returns the static targets followed by the discovered targets, without the discovered targets
+// with the same name as a previous target.
+func (d *Discoverer) Targets() []*config.Target {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.targets
+}
+
+// Refresh loads the targets from the files and the HTTP endpoint once. The targets of a source that fails
+// to load are kept from its previous load.
+func (d *Discoverer) Refresh(ctx context.Context) error {
+	var errs []error
+
+	if d.files != nil {
+		if err := d.files.load(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if d.http != nil {
+		if err := d.http.load(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	d.merge()
+
+	return errors.Join(errs...)
+}
+
+// Run watches the directory and polls the HTTP endpoint for changes to the targets, until it is shut down.
+// The targets should be refreshed before.
+func (d *Discoverer) Run() error {
+	defer close(d.done)
+
+	if !d.Enabled() {
+		<-d.ctx.Done()
+
+		return nil
+	}
+
+	var (
+		changes <-chan struct{}
+		poll    <-chan time.Time
+	)
+
+	if d.files != nil {
+		watched, err := d.files.watch(d.ctx)
+		if err != nil {
+			return err
+		}
+
+		changes = watched
+	}
+
+	if d.http != nil {
+		ticker := time.NewTicker(d.http.refreshInterval)
+		defer ticker.Stop()
+
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return nil
+		case <-changes:
+			if err := d.files.load(); err != nil {
+				slog.Error("error loading target files", "error", err)
+			}
+		case <-poll:
+			if err := d.http.load(d.ctx); err != nil {
+				slog.Error("error loading targets from HTTP", "error", err)
+			}
+		}
+
+		d.merge()
+	}
+}
+
+// Shutdown stops watching for changes to the targets, waiting for Run to return until the context is done.
+func (d *Discoverer) Shutdown(ctx context.Context) error {
+	d.cancel()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error stopping target discovery: %w", ctx.Err())
+	}
+}
+
+// merge updates the targets from the static targets and the targets of the sources. Duplicate targets are
+// warned about when they first appear, rather than on every refresh.
+func (d *Discoverer) merge() {
+	sources := [][]*config.Target{d.static}
+
+	if d.files != nil {
+		sources = append(sources, d.files.current())
+	}
+
+	if d.http != nil {
+		sources = append(sources, d.http.current())
+	}
+
+	seen := map[string]bool{}
+
+	var targets, ignored []*config.Target
+
+	for _, source := range sources {
+		for _, target := range source {
+			if seen[target.Name] {
+				ignored = append(ignored, target)
+
+				continue
+			}
+
+			seen[target.Name] = true
+			targets = append(targets, target)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	duplicates := map[string]bool{}
+
+	for _, target := range ignored {
+		key := target.Name + " " + target.URL
+		if !d.duplicates[key] && !duplicates[key] {
+			slog.Warn("ignoring duplicate target", "name", target.Name, "url", target.URL)
+		}
+
+		duplicates[key] = true
+	}
+
+	d.targets = targets
+	d.duplicates = duplicates
+}
+
+// decodeTargets decodes the targets like the static targets of the configuration, checking they have
+// a name and URL and no secret reference, and reading their token files.
+func decodeTargets(input any) ([]*config.Target, error) {
+	var targets []*config.Target
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           &targets,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating decoder: %w", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		return nil, fmt.Errorf("error decoding targets: %w", err)
+	}
+
+	for i, target := range targets {
+		if target == nil || target.Name == "" || target.URL == "" {
+			return nil, fmt.Errorf("%w: target %d must have a name and url", ErrInvalidTarget, i)
+		}
+
+		// Secret references would let whoever controls the discovered targets run commands and read the files
+		// and environment variables of the exporter.
+		if _, _, ok := secrets.ParseReference(target.Token); ok {
+			return nil, fmt.Errorf("%w: target %s must not reference a secret", ErrInvalidTarget, target.Name)
+		}
+	}
+
+	if err := config.ReadTokenFiles(targets); err != nil {
+		return nil, fmt.Errorf("error reading token files: %w", err)
+	}
+
+	return targets, nil
+}
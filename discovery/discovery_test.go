@@ -0,0 +1,260 @@
+package discovery_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// targetNames returns the names of the targets.
+func targetNames(targets []*config.Target) []string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+
+	return names
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestDiscoverer_Files(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "b.yaml"), `
+- name: New York
+  url: https://cc2.example.com
+  cloud_name: cc2
+  labels:
+    region: us-east
+- name: London
+  url: https://duplicate.example.com
+`)
+	writeFile(t, filepath.Join(dir, "a.json"),
+		`[{"name": "Paris", "url": "https://cc3.example.com", "cloud_name": "cc3"}]`)
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not targets")
+
+	static := []*config.Target{{Name: "London", URL: "https://cc1.example.com", CloudName: "cc1"}}
+	d := discovery.New(static, discovery.Config{Files: discovery.FilesConfig{Directory: dir}})
+
+	assert.True(t, d.Enabled())
+	assert.Equal(t, []string{"London"}, targetNames(d.Targets()))
+
+	require.NoError(t, d.Refresh(context.Background()))
+
+	targets := d.Targets()
+	assert.Equal(t, []string{"London", "Paris", "New York"}, targetNames(targets))
+	assert.Equal(t, "https://cc1.example.com", targets[0].URL)
+	assert.Equal(t, map[string]string{"region": "us-east"}, targets[2].Labels)
+
+	// A file that cannot be loaded keeps its previous targets.
+	writeFile(t, filepath.Join(dir, "a.json"), `[{"name": "Paris"`)
+	writeFile(t, filepath.Join(dir, "c.yaml"), `[{"name": "Tokyo"}]`)
+
+	err := d.Refresh(context.Background())
+	require.Error(t, err)
+	require.ErrorIs(t, err, discovery.ErrInvalidTarget)
+	assert.Equal(t, []string{"London", "Paris", "New York"}, targetNames(d.Targets()))
+
+	// Removed files no longer have targets.
+	require.NoError(t, os.Remove(filepath.Join(dir, "a.json")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "c.yaml")))
+	require.NoError(t, d.Refresh(context.Background()))
+	assert.Equal(t, []string{"London", "New York"}, targetNames(d.Targets()))
+}
+
+func TestDiscoverer_SecretReferences(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "executed")
+
+	writeFile(t, filepath.Join(dir, "targets.yaml"), fmt.Sprintf(`
+- name: London
+  url: https://cc1.example.com
+  token: ${exec:touch %s}
+`, marker))
+
+	d := discovery.New(nil, discovery.Config{Files: discovery.FilesConfig{Directory: dir}})
+
+	err := d.Refresh(context.Background())
+	require.ErrorIs(t, err, discovery.ErrInvalidTarget)
+	require.ErrorContains(t, err, "London must not reference a secret")
+	assert.Empty(t, d.Targets())
+	assert.NoFileExists(t, marker)
+}
+
+func TestDiscoverer_WatchFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	d := discovery.New(nil, discovery.Config{Files: discovery.FilesConfig{Directory: dir}})
+	require.NoError(t, d.Refresh(context.Background()))
+	assert.Empty(t, d.Targets())
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- d.Run()
+	}()
+
+	// Files written before the directory is watched are picked up by the next change.
+	require.Eventually(t, func() bool {
+		writeFile(t, filepath.Join(dir, "targets.yaml"), `[{"name": "London", "url": "https://cc1.example.com"}]`)
+
+		return len(d.Targets()) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "targets.yaml")))
+	require.Eventually(t, func() bool { return len(d.Targets()) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, d.Shutdown(context.Background()))
+	require.NoError(t, <-errs)
+}
+
+func TestDiscoverer_WatchSymlinkedFiles(t *testing.T) {
+	t.Parallel()
+
+	// Kubernetes mounts ConfigMaps as symlinks to the files of a ..data directory symlink, which it swaps
+	// on updates, so the target files themselves are never written.
+	dir := t.TempDir()
+	writeData := func(name, content string) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0o700))
+		writeFile(t, filepath.Join(dir, name, "targets.yaml"), content)
+		require.NoError(t, os.Symlink(name, filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	}
+
+	writeData("..v1", `[{"name": "London", "url": "https://cc1.example.com"}]`)
+	require.NoError(t, os.Symlink(filepath.Join("..data", "targets.yaml"), filepath.Join(dir, "targets.yaml")))
+
+	d := discovery.New(nil, discovery.Config{Files: discovery.FilesConfig{Directory: dir}})
+	require.NoError(t, d.Refresh(context.Background()))
+	assert.Equal(t, []string{"London"}, targetNames(d.Targets()))
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- d.Run()
+	}()
+
+	version := 1
+
+	require.Eventually(t, func() bool {
+		version++
+		writeData(fmt.Sprintf("..v%d", version), `[{"name": "Paris", "url": "https://cc3.example.com"}]`)
+
+		names := targetNames(d.Targets())
+
+		return len(names) == 1 && names[0] == "Paris"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, d.Shutdown(context.Background()))
+	require.NoError(t, <-errs)
+}
+
+func TestDiscoverer_HTTP(t *testing.T) {
+	t.Parallel()
+
+	var (
+		requests atomic.Int64
+		failing  atomic.Bool
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`[
+			{"name": "London", "url": "https://cc1.example.com", "discover_clouds": {"enabled": true}},
+			{"name": "Paris", "url": "https://cc3.example.com", "cloud_name": "cc3"}
+		]`))
+	}))
+	defer server.Close()
+
+	static := []*config.Target{{Name: "Paris", URL: "https://static.example.com", CloudName: "cc3"}}
+	d := discovery.New(static, discovery.Config{HTTP: discovery.HTTPConfig{
+		URL:             server.URL,
+		RefreshInterval: 10 * time.Millisecond,
+		Headers:         map[string]string{"Authorization": "Bearer secret"},
+	}})
+
+	require.NoError(t, d.Refresh(context.Background()))
+
+	targets := d.Targets()
+	assert.Equal(t, []string{"Paris", "London"}, targetNames(targets))
+	assert.Equal(t, "https://static.example.com", targets[0].URL)
+	assert.True(t, targets[1].DiscoverClouds.Enabled)
+
+	// The targets are kept while the endpoint fails.
+	failing.Store(true)
+
+	err := d.Refresh(context.Background())
+	require.ErrorIs(t, err, discovery.ErrUnexpectedStatus)
+	assert.Equal(t, []string{"Paris", "London"}, targetNames(d.Targets()))
+
+	// The endpoint is polled while running.
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- d.Run()
+	}()
+
+	polled := requests.Load()
+	require.Eventually(t, func() bool { return requests.Load() > polled+1 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, d.Shutdown(context.Background()))
+	require.NoError(t, <-errs)
+}
+
+func TestDiscoverer_Disabled(t *testing.T) {
+	t.Parallel()
+
+	static := []*config.Target{{Name: "London", URL: "https://cc1.example.com"}}
+	d := discovery.New(static, discovery.Config{})
+
+	assert.False(t, d.Enabled())
+	require.NoError(t, d.Refresh(context.Background()))
+	assert.Equal(t, static, d.Targets())
+}
+
+func TestDiscoverer_HTTPResponseTooLarge(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"name": "London", "url": "https://cc1.example.com", "labels": {"padding": "`))
+		_, _ = w.Write([]byte(strings.Repeat("x", 10<<20)))
+		_, _ = w.Write([]byte(`"}}]`))
+	}))
+	defer server.Close()
+
+	d := discovery.New(nil, discovery.Config{HTTP: discovery.HTTPConfig{URL: server.URL}})
+
+	require.ErrorIs(t, d.Refresh(context.Background()), discovery.ErrResponseTooLarge)
+	assert.Empty(t, d.Targets())
+}
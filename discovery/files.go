@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/krystal/zadara-exporter/config"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// FilesConfig configures the discovery of targets from a directory of files.
+	FilesConfig struct {
+		// Directory is the directory of the YAML and JSON files, each with a list of targets.
+		Directory string `mapstructure:"directory"`
+	}
+
+	// fileSource loads the targets of the files of a directory.
+	fileSource struct {
+		directory string
+
+		mu      sync.Mutex
+		targets map[string][]*config.Target
+	}
+)
+
+// fileExtensions are the extensions of the target files. JSON files are decoded as YAML.
+var fileExtensions = []string{".yaml", ".yml", ".json"}
+
+func newFileSource(cfg FilesConfig) *fileSource {
+	return &fileSource{directory: cfg.Directory, targets: map[string][]*config.Target{}}
+}
+
+// isTargetFile returns whether the file has the extension of a target file.
+func isTargetFile(name string) bool {
+	return slices.Contains(fileExtensions, filepath.Ext(name))
+}
+
+// readTargetFile reads the targets of the file.
+func readTargetFile(path string) ([]*config.Target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	var input any
+	if err := yaml.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	targets, err := decodeTargets(input)
+	if err != nil {
+		return nil, fmt.Errorf("error in %s: %w", path, err)
+	}
+
+	return targets, nil
+}
+
+// load reads the target files of the directory. The targets of a file that cannot be read are kept from
+// its previous load, as it may be part way through being written.
+func (s *fileSource) load() error {
+	entries, err := os.ReadDir(s.directory)
+	if err != nil {
+		return fmt.Errorf("error reading target directory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	targets := map[string][]*config.Target{}
+
+	var errs []error
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isTargetFile(entry.Name()) {
+			continue
+		}
+
+		fileTargets, err := readTargetFile(filepath.Join(s.directory, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			fileTargets = s.targets[entry.Name()]
+		}
+
+		targets[entry.Name()] = fileTargets
+	}
+
+	s.targets = targets
+
+	return errors.Join(errs...)
+}
+
+// current returns the targets of the files, in the order of the file names.
+func (s *fileSource) current() []*config.Target {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.targets))
+	for name := range s.targets {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	var targets []*config.Target
+	for _, name := range names {
+		targets = append(targets, s.targets[name]...)
+	}
+
+	return targets
+}
+
+// watch watches the directory until the context is done, notifying of changes to its files.
+func (s *fileSource) watch(ctx context.Context) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error creating target directory watcher: %w", err)
+	}
+
+	if err := watcher.Add(s.directory); err != nil {
+		return nil, errors.Join(fmt.Errorf("error watching target directory: %w", err), watcher.Close())
+	}
+
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				// Any change in the directory reloads the files, not only changes to the target files, as
+				// Kubernetes updates the files of mounted ConfigMaps and Secrets by swapping a symlink.
+				if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) &&
+					!event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+
+				// Changes are coalesced until the files are loaded.
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case err := <-watcher.Errors:
+				slog.Error("error watching target directory", "error", err)
+			}
+		}
+	}()
+
+	return changes, nil
+}
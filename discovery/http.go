@@ -0,0 +1,154 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+)
+
+type (
+	// HTTPConfig configures the discovery of targets from an HTTP endpoint.
+	HTTPConfig struct {
+		// URL is the endpoint returning a JSON list of targets.
+		URL string `mapstructure:"url"`
+
+		// RefreshInterval is how often the targets are requested. (default: 1m)
+		RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+
+		// Timeout is how long to wait for the targets. (default: 10s)
+		Timeout time.Duration `mapstructure:"timeout"`
+
+		// Headers are added to the requests, such as for authorization.
+		Headers map[string]string `mapstructure:"headers"`
+	}
+
+	// httpSource loads the targets from an HTTP endpoint.
+	httpSource struct {
+		url             string
+		refreshInterval time.Duration
+		timeout         time.Duration
+		headers         map[string]string
+		client          *http.Client
+
+		mu      sync.Mutex
+		targets []*config.Target
+	}
+)
+
+const (
+	// DefaultRefreshInterval is the default interval the targets are requested from the HTTP endpoint.
+	DefaultRefreshInterval = time.Minute
+
+	// DefaultTimeout is the default timeout of the requests to the HTTP endpoint.
+	DefaultTimeout = 10 * time.Second
+
+	// maxResponseSize is the maximum size of the responses of the HTTP endpoint.
+	maxResponseSize = 10 << 20
+)
+
+var (
+	// ErrUnexpectedStatus is returned when the HTTP endpoint does not respond with 200 OK.
+	ErrUnexpectedStatus = errors.New("unexpected status")
+
+	// ErrResponseTooLarge is returned when the response of the HTTP endpoint is larger than the maximum size.
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+func newHTTPSource(cfg HTTPConfig, client *http.Client) *httpSource {
+	s := &httpSource{
+		url:             cfg.URL,
+		refreshInterval: cfg.RefreshInterval,
+		timeout:         cfg.Timeout,
+		headers:         cfg.Headers,
+		client:          client,
+	}
+
+	if s.refreshInterval <= 0 {
+		s.refreshInterval = DefaultRefreshInterval
+	}
+
+	if s.timeout <= 0 {
+		s.timeout = DefaultTimeout
+	}
+
+	return s
+}
+
+// fetch requests the targets from the endpoint.
+func (s *httpSource) fetch(ctx context.Context) ([]*config.Target, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			slog.Error("error closing response body", "error", err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedStatus, res.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if len(body) > maxResponseSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, maxResponseSize)
+	}
+
+	var input any
+	if err := json.Unmarshal(body, &input); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return decodeTargets(input)
+}
+
+// load requests the targets from the endpoint, keeping the previous targets if it fails.
+func (s *httpSource) load(ctx context.Context) error {
+	targets, err := s.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting targets from %s: %w", s.url, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.targets = targets
+
+	return nil
+}
+
+// current returns the targets of the last successful request.
+func (s *httpSource) current() []*config.Target {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.targets
+}
@@ -0,0 +1,432 @@
+// Package e2e_test runs the exporter binary against a simulated Command Center and compares
+// the scraped metrics to golden files.
+//
+// Run the tests with -update to write the golden files after deliberately changing the metrics:
+//
+//	go test ./e2e -update
+package e2e_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	// The commands are imported so the cached test results are invalidated when the exporter changes,
+	// as the binary is built outside of the test.
+	_ "github.com/krystal/zadara-exporter/cmd"
+	"github.com/krystal/zadara-exporter/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:gochecknoglobals // test flag
+var update = flag.Bool("update", false, "update the golden files")
+
+// binary is the path of the exporter binary built for the tests.
+//
+//nolint:gochecknoglobals // built once in TestMain
+var binary string
+
+const (
+	configTemplate = `targets:
+  - name: Primary
+    url: %[1]s
+    token: e2e
+    cloud_name: cc1
+    labels:
+      region: eu-west
+  - name: Secondary
+    url: %[1]s
+    token: e2e-cc2
+    cloud_name: cc2
+`
+
+	readyTimeout = 30 * time.Second
+	pollInterval = 100 * time.Millisecond
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	if testing.Short() {
+		fmt.Println("skipping end-to-end tests in short mode")
+		os.Exit(0)
+	}
+
+	dir, err := os.MkdirTemp("", "zadara-exporter-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	binary = filepath.Join(dir, "zadara-exporter")
+
+	build := exec.Command("go", "build", "-o", binary, "..")
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+
+	code := 1
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "error building exporter:", err)
+	} else {
+		code = m.Run()
+	}
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// startCommandCenter serves the test fixture with a fixed clock, so the capacity never changes.
+func startCommandCenter(t *testing.T) string {
+	t.Helper()
+
+	fixture, err := simulator.LoadFixture(filepath.Join("testdata", "fixture.yaml"))
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(simulator.New(fixture, simulator.WithClock(func() time.Time { return now })))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// freeAddress returns a local address that is free to listen on.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	return addr
+}
+
+// startServer runs the exporter server against the Command Center, with the extra configuration,
+// environment variables and arguments, stopping it at the end of the test.
+// It returns the address the server listens on.
+func startServer(t *testing.T, commandCenterURL, extraConfig string, env []string, args ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	configData := fmt.Sprintf(configTemplate, commandCenterURL) + extraConfig
+	require.NoError(t, os.WriteFile(configFile, []byte(configData), 0o600))
+
+	addr := freeAddress(t)
+
+	var logs bytes.Buffer
+
+	//nolint:gosec // the binary is built by the test
+	cmd := exec.Command(binary, append([]string{
+		"server", "--config", configFile, "--listen_address", addr,
+	}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &logs
+	cmd.Stderr = &logs
+
+	require.NoError(t, cmd.Start())
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+
+		if t.Failed() {
+			t.Logf("exporter logs:\n%s", logs.String())
+		}
+	})
+
+	return addr
+}
+
+// scrape returns the metrics served by the exporter at the address, waiting for it to be ready.
+func scrape(t *testing.T, addr, path string) string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+
+	for {
+		body, err := get(ctx, "http://"+addr+path)
+		if err == nil {
+			return body
+		}
+
+		select {
+		case <-ctx.Done():
+			require.NoError(t, err, "exporter did not become ready")
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func get(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error scraping metrics: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading metrics: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d: %s", res.StatusCode, body) //nolint:err113 // test helper
+	}
+
+	return string(body), nil
+}
+
+// exporterMetrics returns the lines of the exposition for the metrics in the namespace,
+// leaving out the Go runtime and process metrics that change between runs.
+func exporterMetrics(exposition, namespace string) string {
+	var out strings.Builder
+
+	prefix := namespace + "_"
+	scanner := bufio.NewScanner(strings.NewReader(exposition))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		name := line
+		if comment, ok := strings.CutPrefix(line, "# HELP "); ok {
+			name = comment
+		} else if comment, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name = comment
+		}
+
+		if strings.HasPrefix(name, prefix) {
+			out.WriteString(line + "\n")
+		}
+	}
+
+	return out.String()
+}
+
+// assertGolden compares the output to the golden file, or writes it with -update.
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o600))
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run go test ./e2e -update to create the golden file")
+
+	assert.Equal(t, string(want), got, "the metrics changed, run go test ./e2e -update if this is deliberate")
+}
+
+func TestMetricsGolden(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		namespace string
+		config    string
+		env       []string
+		args      []string
+	}{
+		{
+			name:      "metrics",
+			namespace: "zadara",
+		},
+		{
+			name:      "metrics_namespace",
+			namespace: "storage",
+			args:      []string{"--namespace", "storage"},
+		},
+		{
+			name:      "metrics_legacy",
+			namespace: "zadara",
+			env:       []string{"ZADARA_LEGACY_METRIC_NAMES=true"},
+		},
+		{
+			name:      "metrics_labels",
+			namespace: "zadara",
+			config: `labels:
+  drop: [store_name]
+  rename:
+    name: target
+`,
+		},
+		{
+			name:      "metrics_filtered",
+			namespace: "zadara",
+			config: `metrics:
+  groups:
+    ring_balance: false
+  deny: [cache, ".*_bytes"]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			commandCenterURL := startCommandCenter(t)
+			addr := startServer(t, commandCenterURL, tt.config, tt.env, tt.args...)
+
+			// The Command Center listens on a random port, so its host is replaced to keep the golden files stable.
+			got := strings.ReplaceAll(exporterMetrics(scrape(t, addr, "/metrics"), tt.namespace),
+				strings.TrimPrefix(commandCenterURL, "http://"), "command-center")
+
+			assertGolden(t, tt.name, got)
+		})
+	}
+}
+
+// runCommand runs the exporter command against the Command Center with the environment variables,
+// returning its output and error output, and whether it succeeded.
+func runCommand(t *testing.T, commandCenterURL string, env []string, args ...string) (string, string, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(configTemplate, commandCenterURL)), 0o600))
+
+	var stdout, stderr bytes.Buffer
+
+	//nolint:gosec // the binary is built by the test
+	cmd := exec.Command(binary, append(args, "--config", configFile)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	return stdout.String(), stderr.String(), err
+}
+
+func TestScrapeCommand(t *testing.T) {
+	t.Parallel()
+
+	stdout, stderr, err := runCommand(t, startCommandCenter(t), nil, "scrape", "--only", "Secondary")
+	require.NoError(t, err, stderr)
+
+	assertGolden(t, "scrape", stdout)
+
+	_, stderr, err = runCommand(t, startCommandCenter(t), nil, "scrape", "--only", "Missing")
+	require.Error(t, err)
+	assert.Contains(t, stderr, "unknown target: Missing")
+}
+
+func TestScrapeCommand_PartialFailure(t *testing.T) {
+	t.Parallel()
+
+	commandCenterURL := startCommandCenter(t)
+
+	// A third target whose token is rejected by the Command Center.
+	env := []string{
+		"ZADARA_TARGETS_2_NAME=Revoked",
+		"ZADARA_TARGETS_2_URL=" + commandCenterURL,
+		"ZADARA_TARGETS_2_TOKEN=revoked",
+		"ZADARA_TARGETS_2_CLOUD_NAME=cc1",
+	}
+
+	stdout, stderr, err := runCommand(t, commandCenterURL, env, "scrape", "--format", "csv")
+	require.Error(t, err, "the command must fail if a target could not be collected")
+
+	// The metrics of the healthy targets are still printed.
+	assert.Contains(t, stdout, "zadara_target_up,cloud_name=cc1 name=Primary region=eu-west,1")
+	assert.Contains(t, stdout, "zadara_target_up,cloud_name=cc2 name=Secondary,1")
+	assert.Contains(t, stdout, "zadara_target_up,cloud_name=cc1 name=Revoked,0")
+	assert.Contains(t, stdout, "zadara_target_auth_failed,cloud_name=cc1 name=Revoked,1")
+	assert.Contains(t, stderr, "error collecting Revoked")
+}
+
+func TestInventoryCommands(t *testing.T) {
+	t.Parallel()
+
+	for _, command := range []string{"stores", "policies"} {
+		for _, format := range []string{"table", "json", "yaml", "csv"} {
+			t.Run(command+"_"+format, func(t *testing.T) {
+				t.Parallel()
+
+				stdout, stderr, err := runCommand(t, startCommandCenter(t), nil, command, "list", "--output", format)
+				require.NoError(t, err, stderr)
+
+				assertGolden(t, command+"_"+format, stdout)
+			})
+		}
+	}
+}
+
+func TestPoliciesListCommand_Store(t *testing.T) {
+	t.Parallel()
+
+	commandCenterURL := startCommandCenter(t)
+
+	// Stores are matched by name or ID.
+	for _, store := range []string{"store2", "2"} {
+		stdout, stderr, err := runCommand(t, commandCenterURL, nil, "policies", "list", "--store", store, "-o", "csv")
+		require.NoError(t, err, stderr)
+
+		assert.Equal(t, "TARGET,CLOUD,STORE,ID,NAME,STATUS,HEALTH,HEALTH %,FREE,USED,NORMAL %,DEGRADED %,CRITICAL %\n"+
+			"Secondary,cc2,store2,2,2-way-protection,normal,degraded,97.5,1099511627776,1099511627776,97.5,2.5,0\n", stdout)
+	}
+
+	stdout, stderr, err := runCommand(t, commandCenterURL, nil, "policies", "list", "--store", "store3")
+	require.Error(t, err)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "unknown store: store3")
+}
+
+func TestRecordAndReplay_Exclusive(t *testing.T) {
+	t.Parallel()
+
+	commandCenterURL := startCommandCenter(t)
+	dir := t.TempDir()
+
+	_, stderr, err := runCommand(t, commandCenterURL, nil,
+		"stores", "list", "--record-dir", dir, "--replay-dir", dir)
+	require.Error(t, err)
+	assert.Contains(t, stderr, "none of the others can be")
+
+	env := []string{"ZADARA_RECORD_DIR=" + dir, "ZADARA_REPLAY_DIR=" + dir}
+
+	_, stderr, err = runCommand(t, commandCenterURL, env, "stores", "list")
+	require.Error(t, err)
+	assert.Contains(t, stderr, "record_dir and replay_dir cannot both be set")
+}
+
+func TestAddTarget(t *testing.T) {
+	t.Parallel()
+
+	commandCenterURL := startCommandCenter(t)
+
+	// Targets defined with flags can be selected by name.
+	stdout, stderr, err := runCommand(t, commandCenterURL, nil, "stores", "list", "-o", "csv",
+		"--target", "name=Extra,url="+commandCenterURL+",cloud_name=cc2,token=e2e-cc2", "--only", "Extra")
+	require.NoError(t, err, stderr)
+
+	assert.Equal(t, "TARGET,CLOUD,ID,NAME,STATUS,TENANT,OBJECTS,DRIVES\n"+
+		"Extra,cc2,2,store2,normal,globex,9000,6\n", stdout)
+}
@@ -0,0 +1,295 @@
+// Package forecast provides capacity forecasting for storage policies.
+//
+// A Forecaster keeps a rolling window of used capacity samples for each series
+// and fits a least squares linear regression through them to estimate how
+// quickly the capacity is growing and when the free capacity will run out.
+// The samples can be persisted to a state file so that the window survives
+// restarts of the exporter.
+package forecast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// Sample represents a single used capacity observation.
+	Sample struct {
+		Timestamp time.Time `json:"timestamp"`
+		Used      int64     `json:"used"`
+	}
+
+	// Prediction represents the forecast for a single series.
+	Prediction struct {
+		// GrowthBytesPerDay is the rate at which the used capacity is growing.
+		// It is negative when the used capacity is shrinking.
+		GrowthBytesPerDay float64
+
+		// FullAt is the predicted time at which the capacity will be exhausted.
+		// It is the zero time when the used capacity is not growing,
+		// or is growing too slowly to be exhausted within MaxHorizon.
+		FullAt time.Time
+	}
+
+	// Config represents the configuration for a Forecaster.
+	Config struct {
+		// StateFile is the path of the file the samples are persisted to.
+		// If empty, the samples are only kept in memory.
+		StateFile string `mapstructure:"state_file"`
+
+		// Window is how far back samples are kept for.
+		Window time.Duration `mapstructure:"window"`
+
+		// SampleInterval is the minimum time between two samples of a series.
+		SampleInterval time.Duration `mapstructure:"sample_interval"`
+
+		// MinSamples is the number of samples required before a prediction is made.
+		MinSamples int `mapstructure:"min_samples"`
+	}
+
+	// Forecaster keeps a rolling window of used capacity samples per series.
+	Forecaster struct {
+		mu     sync.Mutex
+		config Config
+		series map[string][]Sample
+		dirty  bool
+	}
+
+	// state represents the persisted state of a Forecaster.
+	state struct {
+		Version int                 `json:"version"`
+		Series  map[string][]Sample `json:"series"`
+	}
+)
+
+const (
+	// DefaultWindow is the default window of samples kept per series.
+	DefaultWindow = 14 * 24 * time.Hour
+
+	// DefaultSampleInterval is the default minimum time between two samples of a series.
+	DefaultSampleInterval = 15 * time.Minute
+
+	// DefaultMinSamples is the default number of samples required before a prediction is made.
+	DefaultMinSamples = 3
+
+	// MaxHorizon is how far ahead the time at which the capacity will be exhausted is predicted.
+	MaxHorizon = 100 * 365 * 24 * time.Hour
+
+	// minSamples is the fewest samples a line can be fitted through.
+	minSamples = 2
+
+	stateVersion  = 1
+	secondsPerDay = 24 * 60 * 60
+	stateFileMode = 0o600
+)
+
+// ErrUnsupportedStateVersion is returned when the state file has an unknown version.
+var ErrUnsupportedStateVersion = errors.New("unsupported forecast state version")
+
+// New creates a new Forecaster using the provided configuration.
+// Zero values in the configuration are replaced with their defaults.
+func New(config Config) *Forecaster {
+	if config.Window <= 0 {
+		config.Window = DefaultWindow
+	}
+
+	if config.SampleInterval <= 0 {
+		config.SampleInterval = DefaultSampleInterval
+	}
+
+	if config.MinSamples < minSamples {
+		config.MinSamples = DefaultMinSamples
+	}
+
+	return &Forecaster{
+		config: config,
+		series: make(map[string][]Sample),
+	}
+}
+
+// Add records a sample for the series identified by key.
+// The sample is ignored if it is within the sample interval of the previous sample.
+// Samples older than the window, relative to the new sample, are discarded.
+// It returns true if the sample was recorded.
+func (f *Forecaster) Add(key string, sample Sample) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	samples := f.series[key]
+	if n := len(samples); n > 0 && sample.Timestamp.Sub(samples[n-1].Timestamp) < f.config.SampleInterval {
+		return false
+	}
+
+	samples = append(samples, sample)
+
+	cutoff := sample.Timestamp.Add(-f.config.Window)
+
+	first := 0
+	for first < len(samples) && samples[first].Timestamp.Before(cutoff) {
+		first++
+	}
+
+	f.series[key] = samples[first:]
+	f.dirty = true
+
+	return true
+}
+
+// Predict returns the prediction for the series identified by key.
+// The free capacity is the currently available capacity of the series.
+// It returns false if there are not enough samples to make a prediction.
+func (f *Forecaster) Predict(key string, free int64) (Prediction, bool) {
+	f.mu.Lock()
+	samples := append([]Sample(nil), f.series[key]...)
+	f.mu.Unlock()
+
+	if len(samples) < f.config.MinSamples {
+		return Prediction{}, false
+	}
+
+	origin := samples[0].Timestamp
+
+	slope, intercept, ok := linearRegression(samples, origin)
+	if !ok {
+		return Prediction{}, false
+	}
+
+	prediction := Prediction{
+		GrowthBytesPerDay: slope * secondsPerDay,
+	}
+
+	if slope > 0 {
+		last := samples[len(samples)-1]
+		total := float64(last.Used + free)
+		seconds := (total - intercept) / slope
+
+		// The duration overflows for tiny slopes, so only predict within the horizon.
+		if seconds <= last.Timestamp.Sub(origin).Seconds()+MaxHorizon.Seconds() {
+			prediction.FullAt = origin.Add(time.Duration(seconds * float64(time.Second)))
+		}
+	}
+
+	return prediction, true
+}
+
+// Prune discards the series without any sample within the window before now,
+// such as those of removed policies.
+func (f *Forecaster) Prune(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cutoff := now.Add(-f.config.Window)
+
+	for key, samples := range f.series {
+		if len(samples) == 0 || samples[len(samples)-1].Timestamp.Before(cutoff) {
+			delete(f.series, key)
+
+			f.dirty = true
+		}
+	}
+}
+
+// Load reads the samples from the state file.
+// A missing state file is not an error.
+func (f *Forecaster) Load() error {
+	if f.config.StateFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.config.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("error reading forecast state: %w", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("error decoding forecast state: %w", err)
+	}
+
+	if st.Version != stateVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedStateVersion, st.Version)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if st.Series != nil {
+		f.series = st.Series
+	}
+
+	return nil
+}
+
+// Save writes the samples to the state file if they have changed since the last save.
+// The file is written to a temporary file first and then renamed into place.
+func (f *Forecaster) Save() error {
+	if f.config.StateFile == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(state{Version: stateVersion, Series: f.series})
+	if err != nil {
+		return fmt.Errorf("error encoding forecast state: %w", err)
+	}
+
+	tmp := f.config.StateFile + ".tmp"
+
+	if err := os.WriteFile(tmp, data, stateFileMode); err != nil {
+		return fmt.Errorf("error writing forecast state: %w", err)
+	}
+
+	if err := os.Rename(tmp, f.config.StateFile); err != nil {
+		return fmt.Errorf("error replacing forecast state file: %w", err)
+	}
+
+	f.dirty = false
+
+	return nil
+}
+
+// linearRegression fits a least squares line through the samples.
+// The x axis is the number of seconds since origin and the y axis is the used capacity.
+// It returns false if the samples do not span any time.
+func linearRegression(samples []Sample, origin time.Time) (float64, float64, bool) {
+	n := float64(len(samples))
+
+	var sumX, sumY float64
+
+	for _, s := range samples {
+		sumX += s.Timestamp.Sub(origin).Seconds()
+		sumY += float64(s.Used)
+	}
+
+	meanX, meanY := sumX/n, sumY/n
+
+	var covariance, variance float64
+
+	for _, s := range samples {
+		dx := s.Timestamp.Sub(origin).Seconds() - meanX
+		covariance += dx * (float64(s.Used) - meanY)
+		variance += dx * dx
+	}
+
+	if variance == 0 {
+		return 0, 0, false
+	}
+
+	slope := covariance / variance
+
+	return slope, meanY - slope*meanX, true
+}
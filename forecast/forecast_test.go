@@ -0,0 +1,167 @@
+package forecast_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/forecast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForecaster_Predict(t *testing.T) {
+	t.Parallel()
+
+	f := forecast.New(forecast.Config{SampleInterval: time.Hour})
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// Grow by 1000 bytes a day, sampled every 12 hours.
+	for i := range 5 {
+		require.True(t, f.Add("policy", forecast.Sample{
+			Timestamp: start.Add(time.Duration(i) * 12 * time.Hour),
+			Used:      int64(i) * 500,
+		}))
+	}
+
+	prediction, ok := f.Predict("policy", 3000)
+	require.True(t, ok)
+
+	assert.InDelta(t, 1000, prediction.GrowthBytesPerDay, 0.001)
+	// 2000 bytes used after two days, 3000 bytes free is three more days.
+	assert.WithinDuration(t, start.Add(5*24*time.Hour), prediction.FullAt, time.Second)
+}
+
+func TestForecaster_PredictNotGrowing(t *testing.T) {
+	t.Parallel()
+
+	f := forecast.New(forecast.Config{SampleInterval: time.Hour})
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := range 3 {
+		f.Add("policy", forecast.Sample{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Used:      100 - int64(i),
+		})
+	}
+
+	prediction, ok := f.Predict("policy", 100)
+	require.True(t, ok)
+
+	assert.Negative(t, prediction.GrowthBytesPerDay)
+	assert.True(t, prediction.FullAt.IsZero())
+}
+
+func TestForecaster_PredictBeyondHorizon(t *testing.T) {
+	t.Parallel()
+
+	f := forecast.New(forecast.Config{SampleInterval: time.Hour})
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// Grow by a single byte over two days, which would take far longer than the horizon to fill up.
+	for i := range 3 {
+		f.Add("policy", forecast.Sample{
+			Timestamp: start.Add(time.Duration(i) * 24 * time.Hour),
+			Used:      1_000_000_000_000 + int64(i/2),
+		})
+	}
+
+	prediction, ok := f.Predict("policy", 1<<50)
+	require.True(t, ok)
+
+	assert.Positive(t, prediction.GrowthBytesPerDay)
+	assert.True(t, prediction.FullAt.IsZero())
+}
+
+func TestForecaster_Prune(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "forecast.json")
+	f := forecast.New(forecast.Config{StateFile: stateFile, Window: 24 * time.Hour})
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	f.Add("removed", forecast.Sample{Timestamp: now.Add(-48 * time.Hour), Used: 1})
+	f.Add("current", forecast.Sample{Timestamp: now.Add(-time.Hour), Used: 1})
+
+	f.Prune(now)
+	require.NoError(t, f.Save())
+
+	data, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(data), `"current"`)
+	assert.NotContains(t, string(data), `"removed"`)
+}
+
+func TestForecaster_PredictNotEnoughSamples(t *testing.T) {
+	t.Parallel()
+
+	f := forecast.New(forecast.Config{})
+	f.Add("policy", forecast.Sample{Timestamp: time.Now(), Used: 1})
+
+	_, ok := f.Predict("policy", 100)
+	assert.False(t, ok)
+
+	_, ok = f.Predict("unknown", 100)
+	assert.False(t, ok)
+}
+
+func TestForecaster_Add(t *testing.T) {
+	t.Parallel()
+
+	f := forecast.New(forecast.Config{
+		Window:         3 * time.Hour,
+		SampleInterval: time.Hour,
+		MinSamples:     2,
+	})
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.True(t, f.Add("policy", forecast.Sample{Timestamp: start, Used: 1000}))
+	// Within the sample interval so it is ignored.
+	assert.False(t, f.Add("policy", forecast.Sample{Timestamp: start.Add(time.Minute), Used: 0}))
+
+	for i := 1; i <= 4; i++ {
+		assert.True(t, f.Add("policy", forecast.Sample{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Used:      int64(i) * 10,
+		}))
+	}
+
+	// The first sample has left the window, so it no longer skews the line.
+	prediction, ok := f.Predict("policy", 0)
+	require.True(t, ok)
+	assert.InDelta(t, 240, prediction.GrowthBytesPerDay, 0.001)
+}
+
+func TestForecaster_SaveLoad(t *testing.T) {
+	t.Parallel()
+
+	config := forecast.Config{
+		StateFile:      filepath.Join(t.TempDir(), "forecast.json"),
+		SampleInterval: time.Hour,
+	}
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	f := forecast.New(config)
+	require.NoError(t, f.Load())
+
+	for i := range 3 {
+		f.Add("policy", forecast.Sample{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Used:      int64(i) * 100,
+		})
+	}
+
+	require.NoError(t, f.Save())
+
+	restored := forecast.New(config)
+	require.NoError(t, restored.Load())
+
+	want, ok := f.Predict("policy", 1000)
+	require.True(t, ok)
+
+	got, ok := restored.Predict("policy", 1000)
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+}
@@ -7,17 +7,33 @@ import (
 
 	"github.com/krystal/zadara-exporter/config"
 	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
 )
 
 // Handler is an HTTP handler for healthchecking purposes.
-type Handler struct{}
+type Handler struct {
+	// Targets returns the targets to check, defaulting to the targets of the configuration.
+	Targets func() []*config.Target
+
+	// ClientOptions are the options of the Command Center clients used to check the targets.
+	ClientOptions []commandcenter.Option
+}
 
 // DefaultPath is the default path for the healthcheck handler.
 const DefaultPath = "/healthz"
 
+// targets returns the targets to check.
+func (h *Handler) targets() ([]*config.Target, error) {
+	if h.Targets != nil {
+		return h.Targets(), nil
+	}
+
+	return config.GetTargets() //nolint:wrapcheck // logged as is
+}
+
 // ServeHTTP handles the HTTP request and returns a health status.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	targets, err := config.GetTargets()
+	targets, err := h.targets()
 	if err != nil {
 		slog.Error("Error getting targets", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -29,14 +45,15 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	for _, target := range targets {
 		slog.Debug("Checking target", "target", target.Name)
-		client := commandcenter.NewClient(target)
+		client := commandcenter.NewClient(target, h.ClientOptions...)
 
-		_, err := client.GetStores(r.Context(), target.CloudName)
-		if err != nil {
+		if err := client.VerifyToken(r.Context(), target); err != nil {
 			slog.Error("Error getting stores",
 				"name", target.Name,
 				"cloud_name", target.CloudName,
 				"url", target.URL,
+				"auth_failed", vpsaobjectstorage.IsUnauthorized(err),
+				"token_fingerprint", client.TokenFingerprint(r.Context()),
 				"error", err)
 
 			couldNotConnect = true
@@ -54,11 +71,23 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // RegisterHandler registers the health handler to the provided router.
-func RegisterHandler(router *http.ServeMux, path string) {
+// The options are used for the Command Center clients checking the targets.
+func RegisterHandler(router *http.ServeMux, path string, opts ...commandcenter.Option) {
+	RegisterHandlerWithTargets(router, path, nil, opts...)
+}
+
+// RegisterHandlerWithTargets registers the health handler checking the targets returned by the function,
+// such as the discovered targets, to the provided router.
+func RegisterHandlerWithTargets(
+	router *http.ServeMux,
+	path string,
+	targets func() []*config.Target,
+	opts ...commandcenter.Option,
+) {
 	if path == "" {
 		path = DefaultPath
 	}
 
-	handler := &Handler{}
+	handler := &Handler{Targets: targets, ClientOptions: opts}
 	router.Handle(path, handler)
 }
@@ -0,0 +1,153 @@
+// Package lifecycle runs the long running services of the exporter and shuts them down gracefully.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type (
+	// RunFunc runs a service until it fails or is shut down.
+	// It should return nil when the service was shut down.
+	RunFunc func() error
+
+	// ShutdownFunc gracefully shuts down a service, giving up when the context is done.
+	ShutdownFunc func(ctx context.Context) error
+
+	// Manager runs services until the context is done or any of them stop,
+	// and then shuts them down in the reverse order they were added, within a grace period.
+	Manager struct {
+		grace    time.Duration
+		services []service
+	}
+
+	service struct {
+		name     string
+		run      RunFunc
+		shutdown ShutdownFunc
+	}
+)
+
+// DefaultGracePeriod is the default time the services are given to shut down.
+const DefaultGracePeriod = 30 * time.Second
+
+// ErrShutdownTimeout is returned when the services did not shut down within the grace period.
+var ErrShutdownTimeout = errors.New("timed out waiting for services to shut down")
+
+// New creates a new manager with the grace period, or the default grace period if it is not positive.
+func New(grace time.Duration) *Manager {
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+
+	return &Manager{grace: grace}
+}
+
+// Add adds a service to the manager. The run function may be nil for services that are already running,
+// such as background collectors, which then only need shutting down.
+func (m *Manager) Add(name string, run RunFunc, shutdown ShutdownFunc) {
+	m.services = append(m.services, service{name: name, run: run, shutdown: shutdown})
+}
+
+// Run runs the services until the context is done or any of them stop, and then shuts them all down.
+// It returns the errors of the services that failed, along with any errors shutting them down.
+func (m *Manager) Run(ctx context.Context) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		done   = make(chan struct{})
+		closer sync.Once
+	)
+
+	for _, s := range m.services {
+		if s.run == nil {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := s.run()
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("error running %s: %w", s.name, err))
+				mu.Unlock()
+			}
+
+			if ctx.Err() == nil {
+				slog.Info("service stopped, shutting down", "service", s.name, "error", err)
+			}
+
+			closer.Do(func() { close(done) })
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down", "grace_period", m.grace)
+	case <-done:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.grace)
+	defer cancel()
+
+	shutdownErr := m.shutdown(shutdownCtx)
+
+	stopped := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		if !errors.Is(shutdownErr, ErrShutdownTimeout) {
+			shutdownErr = errors.Join(shutdownErr, ErrShutdownTimeout)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return errors.Join(append(errs, shutdownErr)...)
+}
+
+// Shutdown shuts down the services within the grace period, without running them.
+// It is used to clean up services that were already started when setup fails.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.grace)
+	defer cancel()
+
+	return m.shutdown(ctx)
+}
+
+// shutdown shuts down the services in the reverse order they were added.
+func (m *Manager) shutdown(ctx context.Context) error {
+	var errs []error
+
+	for i := len(m.services) - 1; i >= 0; i-- {
+		s := m.services[i]
+		if s.shutdown == nil {
+			continue
+		}
+
+		if err := s.shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error shutting down %s: %w", s.name, err))
+		}
+	}
+
+	if ctx.Err() != nil {
+		errs = append(errs, ErrShutdownTimeout)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errListen = errors.New("address already in use")
+
+// blockingService returns functions that run until shut down.
+func blockingService(order *[]string, name string) (lifecycle.RunFunc, lifecycle.ShutdownFunc) {
+	stop := make(chan struct{})
+
+	run := func() error {
+		<-stop
+
+		return nil
+	}
+
+	shutdown := func(context.Context) error {
+		*order = append(*order, name)
+		close(stop)
+
+		return nil
+	}
+
+	return run, shutdown
+}
+
+func TestManager_RunContextDone(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+
+	manager := lifecycle.New(time.Second)
+
+	manager.Add("collector", nil, func(context.Context) error {
+		order = append(order, "collector")
+
+		return nil
+	})
+
+	run, shutdown := blockingService(&order, "server")
+	manager.Add("server", run, shutdown)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.NoError(t, manager.Run(ctx))
+	assert.Equal(t, []string{"server", "collector"}, order)
+}
+
+func TestManager_RunServiceFails(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+
+	manager := lifecycle.New(time.Second)
+
+	run, shutdown := blockingService(&order, "collector")
+	manager.Add("collector", run, shutdown)
+	manager.Add("server", func() error { return errListen }, nil)
+
+	err := manager.Run(context.Background())
+	require.ErrorIs(t, err, errListen)
+	assert.Contains(t, err.Error(), "error running server")
+	assert.Equal(t, []string{"collector"}, order)
+}
+
+func TestManager_RunShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	manager := lifecycle.New(10 * time.Millisecond)
+
+	manager.Add("server", func() error {
+		select {}
+	}, func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := manager.Run(ctx)
+	require.ErrorIs(t, err, lifecycle.ErrShutdownTimeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
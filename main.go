@@ -1,6 +1,8 @@
 // Package main provides the entry point for the zadara-exporter.
 package main
 
+//go:generate go run . rules generate --output chart/files/rules.yaml
+
 import (
 	"context"
 	"fmt"
@@ -26,9 +28,11 @@ func mainE() error {
 	)
 	defer cancel()
 
-	err := rootCmd.ExecuteContext(ctx)
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
 
-	return fmt.Errorf("command failed: %w", err)
+	return nil
 }
 
 func main() {
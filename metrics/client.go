@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// The names of the Command Center client metrics.
+const (
+	CircuitBreakerStateName = "client_circuit_breaker_state"
+	RejectedRequestsName    = "client_rejected_requests"
+)
+
+// The reasons requests are rejected, as the value of the reason label of RejectedRequestsName.
+const (
+	RateLimitedReason = "rate_limited"
+	CircuitOpenReason = "circuit_open"
+)
+
+// reasonLabel is the label of the reason requests are rejected.
+const reasonLabel = "reason"
+
+// RegisterClientMetrics registers the metrics of the Command Center client limiters on the global meter provider.
+func RegisterClientMetrics(limiters *commandcenter.Limiters, targets []*config.Target, opts ...Option) error {
+	return RegisterClientMetricsWithMeter(otel.Meter(meterName), limiters, targets, opts...)
+}
+
+// RegisterClientMetricsWithMeter registers the metrics of the Command Center client limiters on the meter:
+// the state of the circuit breaker and the number of rejected requests of each Command Center.
+// They are labelled with the Command Center and the static labels its targets share,
+// as configured by the labels and targets options of the storage metrics.
+func RegisterClientMetricsWithMeter(
+	meter metric.Meter,
+	limiters *commandcenter.Limiters,
+	targets []*config.Target,
+	opts ...Option,
+) error {
+	sm := &StorageMetrics{}
+	for _, opt := range opts {
+		opt(sm)
+	}
+
+	state, err := meter.Int64ObservableGauge(CircuitBreakerStateName, metric.WithDescription(
+		"The state of the circuit breaker of the Command Center client: 0 closed, 1 open or 2 half-open."))
+	if err != nil {
+		return fmt.Errorf("failed to create %s gauge: %w", CircuitBreakerStateName, err)
+	}
+
+	rejected, err := meter.Int64ObservableCounter(RejectedRequestsName, metric.WithDescription(
+		"The number of requests to the Command Center rejected by the rate limiter or the circuit breaker."))
+	if err != nil {
+		return fmt.Errorf("failed to create %s counter: %w", RejectedRequestsName, err)
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		current, _ := sm.currentTargets(targets)
+		commandCenters := commandCenterTargets(current)
+
+		for _, stats := range limiters.Stats() {
+			target, ok := commandCenters[stats.CommandCenter]
+			if !ok {
+				target = &config.Target{}
+			}
+
+			// The attributes are clipped so appending the reason never writes to a shared backing array.
+			attrs := slices.Clip(sm.labels.attributes(target, attribute.String(CommandCenterLabel, stats.CommandCenter)))
+
+			observer.ObserveInt64(state, int64(stats.State), metric.WithAttributes(attrs...))
+			observer.ObserveInt64(rejected, stats.RateLimited,
+				metric.WithAttributes(append(attrs, attribute.String(reasonLabel, RateLimitedReason))...))
+			observer.ObserveInt64(rejected, stats.CircuitOpen,
+				metric.WithAttributes(append(attrs, attribute.String(reasonLabel, CircuitOpenReason))...))
+		}
+
+		return nil
+	}, state, rejected)
+	if err != nil {
+		return fmt.Errorf("failed to register client metrics: %w", err)
+	}
+
+	return nil
+}
+
+// commandCenterTargets returns a target for each Command Center of the targets, keyed by its host,
+// with the static labels all its targets have with the same value.
+func commandCenterTargets(targets []*config.Target) map[string]*config.Target {
+	commandCenters := map[string]*config.Target{}
+
+	for _, target := range targets {
+		host := commandcenter.CommandCenterHost(target)
+
+		shared, ok := commandCenters[host]
+		if !ok {
+			labels := make(map[string]string, len(target.Labels))
+			for name, value := range target.Labels {
+				labels[name] = value
+			}
+
+			commandCenters[host] = &config.Target{URL: target.URL, Labels: labels}
+
+			continue
+		}
+
+		for name, value := range shared.Labels {
+			if target.Labels[name] != value {
+				delete(shared.Labels, name)
+			}
+		}
+	}
+
+	return commandCenters
+}
@@ -0,0 +1,73 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRegisterClientMetrics(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	targets := []*config.Target{
+		{Name: "London", URL: server.URL, Labels: map[string]string{"region": "eu-west", "environment": "production"}},
+		{Name: "Paris", URL: server.URL, Labels: map[string]string{"region": "eu-west", "environment": "staging"}},
+	}
+
+	limiters := commandcenter.NewLimiters(commandcenter.RateLimitConfig{}, commandcenter.DefaultBreakerConfig)
+	client := commandcenter.NewClient(targets[0], commandcenter.WithLimiters(limiters))
+
+	for range commandcenter.DefaultBreakerConfig.FailureThreshold + 2 {
+		_, err := client.GetStores(context.Background(), "cc1")
+		require.Error(t, err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	require.NoError(t, metrics.RegisterClientMetricsWithMeter(meter, limiters, targets,
+		metrics.WithLabels(metrics.LabelConfig{Rename: map[string]string{metrics.CommandCenterLabel: "cc"}})))
+
+	// The series are labelled with the Command Center and the static labels its targets share.
+	host := server.Listener.Addr().String()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	assert.ElementsMatch(t, []metrics.Sample{
+		{
+			Name:        "zadara_client_circuit_breaker_state",
+			Description: "The state of the circuit breaker of the Command Center client: 0 closed, 1 open or 2 half-open.",
+			Labels:      map[string]string{"cc": host, "region": "eu-west"},
+			Value:       float64(commandcenter.BreakerOpen),
+		},
+		{
+			Name:        "zadara_client_rejected_requests_total",
+			Description: "The number of requests to the Command Center rejected by the rate limiter or the circuit breaker.",
+			Labels:      map[string]string{"cc": host, "region": "eu-west", "reason": "rate_limited"},
+			Value:       0,
+			Counter:     true,
+		},
+		{
+			Name:        "zadara_client_rejected_requests_total",
+			Description: "The number of requests to the Command Center rejected by the rate limiter or the circuit breaker.",
+			Labels:      map[string]string{"cc": host, "region": "eu-west", "reason": "circuit_open"},
+			Value:       2,
+			Counter:     true,
+		},
+	}, metrics.Samples("zadara", &rm))
+}
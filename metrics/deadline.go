@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// ScrapeDeadlines tracks the deadlines of the in-flight scrapes. The Prometheus exporter does not pass the
+	// context of the scrape request to the storage metrics callback, so the callback takes its deadline from here.
+	ScrapeDeadlines struct {
+		mu        sync.Mutex
+		next      uint64
+		deadlines map[uint64]time.Time
+	}
+)
+
+// ScrapeTimeoutHeader is the header Prometheus sends with the scrape timeout in seconds.
+const ScrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// DefaultScrapeTimeoutOffset is the default time left between the collection deadline and the scrape timeout,
+// to write the response before Prometheus gives up on the scrape.
+const DefaultScrapeTimeoutOffset = 500 * time.Millisecond
+
+// MaxScrapeTimeout is the longest scrape timeout taken from the header, as longer ones are not meaningful
+// and would overflow the deadline.
+const MaxScrapeTimeout = time.Hour
+
+// NewScrapeDeadlines returns a tracker of the deadlines of the in-flight scrapes.
+func NewScrapeDeadlines() *ScrapeDeadlines {
+	return &ScrapeDeadlines{deadlines: map[uint64]time.Time{}}
+}
+
+// add adds the deadline of a scrape, returning the function removing it when the scrape is done.
+func (d *ScrapeDeadlines) add(deadline time.Time) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := d.next
+	d.next++
+	d.deadlines[id] = deadline
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		delete(d.deadlines, id)
+	}
+}
+
+// Context returns a context with the earliest deadline of the in-flight scrapes, or the context itself
+// if there are none. As the callback cannot tell which scrape it collects the metrics for, the collections of
+// concurrent scrapes are all bounded by the earliest of their deadlines, so none of them outlives its scrape.
+func (d *ScrapeDeadlines) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d == nil {
+		return context.WithCancel(ctx)
+	}
+
+	d.mu.Lock()
+
+	var earliest time.Time
+
+	for _, deadline := range d.deadlines {
+		if earliest.IsZero() || deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+
+	d.mu.Unlock()
+
+	if earliest.IsZero() {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, earliest)
+}
+
+// ScrapeTimeoutHandler returns a handler setting the deadline of the scrape from the Prometheus scrape timeout
+// header, less the offset, before serving the request with the next handler.
+// Requests without a finite positive timeout in the header have no deadline, and longer timeouts than
+// MaxScrapeTimeout are clamped to it.
+func ScrapeTimeoutHandler(next http.Handler, offset time.Duration, deadlines *ScrapeDeadlines) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seconds, err := strconv.ParseFloat(r.Header.Get(ScrapeTimeoutHeader), 64)
+		if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds <= 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		// The timeout is clamped before converting it, as huge values overflow the duration.
+		timeout := MaxScrapeTimeout
+		if seconds < MaxScrapeTimeout.Seconds() {
+			timeout = time.Duration(seconds * float64(time.Second))
+		}
+
+		// The offset is only taken off if it leaves time to collect the metrics.
+		if timeout > offset {
+			timeout -= offset
+		}
+
+		deadline := time.Now().Add(timeout)
+
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+
+		done := deadlines.add(deadline)
+		defer done()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,295 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type (
+	// slowZadaraClient is a client that only responds once the context is done.
+	slowZadaraClient struct{}
+
+	// slowCloudsClient is a client discovering the clouds cc1 and cc2 once, and then only once the context is done.
+	// It retrieves the stores of cc1, and only responds for the other clouds once the context is done.
+	slowCloudsClient struct {
+		discovered atomic.Bool
+	}
+)
+
+func (c *slowCloudsClient) CloudTargets(ctx context.Context, target *config.Target) ([]*config.Target, error) {
+	if c.discovered.Swap(true) {
+		<-ctx.Done()
+
+		return nil, ctx.Err() //nolint:wrapcheck // returned as is by the API client
+	}
+
+	return []*config.Target{target.ForCloud("cc1"), target.ForCloud("cc2")}, nil
+}
+
+func (c *slowCloudsClient) GetAllStoragePolicies(ctx context.Context) ([]*commandcenter.StoreStoragePolicies, error) {
+	<-ctx.Done()
+
+	return nil, ctx.Err() //nolint:wrapcheck // returned as is by the API client
+}
+
+func (c *slowCloudsClient) GetStores(ctx context.Context, cloudName string) (*vpsaobjectstorage.ZiosResponse, error) {
+	if cloudName == "cc1" {
+		return &vpsaobjectstorage.ZiosResponse{Zioses: []*vpsaobjectstorage.Zios{{Name: "store1"}}}, nil
+	}
+
+	<-ctx.Done()
+
+	return nil, ctx.Err() //nolint:wrapcheck // returned as is by the API client
+}
+
+func (slowZadaraClient) GetAllStoragePolicies(ctx context.Context) ([]*commandcenter.StoreStoragePolicies, error) {
+	<-ctx.Done()
+
+	return nil, ctx.Err() //nolint:wrapcheck // returned as is by the API client
+}
+
+func (slowZadaraClient) GetStores(ctx context.Context, _ string) (*vpsaobjectstorage.ZiosResponse, error) {
+	<-ctx.Done()
+
+	return nil, ctx.Err() //nolint:wrapcheck // returned as is by the API client
+}
+
+func TestScrapeTimeoutHandler(t *testing.T) {
+	t.Parallel()
+
+	deadlines := metrics.NewScrapeDeadlines()
+
+	var (
+		requestDeadline   time.Time
+		collectorDeadline time.Time
+	)
+
+	handler := metrics.ScrapeTimeoutHandler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		requestDeadline, _ = r.Context().Deadline()
+
+		ctx, cancel := deadlines.Context(context.Background())
+		defer cancel()
+
+		collectorDeadline, _ = ctx.Deadline()
+	}), time.Second, deadlines)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set(metrics.ScrapeTimeoutHeader, "10")
+
+	start := time.Now()
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.WithinDuration(t, start.Add(9*time.Second), requestDeadline, time.Second)
+	assert.Equal(t, requestDeadline, collectorDeadline)
+
+	// The deadline is removed when the scrape is done.
+	ctx, cancel := deadlines.Context(context.Background())
+	defer cancel()
+
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+
+	// Scrapes without the header have no deadline.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.True(t, requestDeadline.IsZero())
+	assert.True(t, collectorDeadline.IsZero())
+}
+
+func TestScrapeTimeoutHandler_InvalidTimeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "NaN"},
+		{header: "+Inf"},
+		{header: "-5"},
+		{header: "soon"},
+		{header: "1e300", want: metrics.MaxScrapeTimeout},
+		{header: "7200", want: metrics.MaxScrapeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				deadline time.Time
+				ok       bool
+			)
+
+			handler := metrics.ScrapeTimeoutHandler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				deadline, ok = r.Context().Deadline()
+			}), 0, metrics.NewScrapeDeadlines())
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set(metrics.ScrapeTimeoutHeader, tt.header)
+
+			start := time.Now()
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.want == 0 {
+				assert.False(t, ok, "no deadline")
+
+				return
+			}
+
+			require.True(t, ok)
+			assert.WithinDuration(t, start.Add(tt.want), deadline, time.Second)
+		})
+	}
+}
+
+func TestStorageMetricsScrapeTimeout(t *testing.T) {
+	t.Parallel()
+
+	fastClient := new(mockZadaraClient)
+	fastClient.On("GetStores", mock.Anything, "cc1").Return(&vpsaobjectstorage.ZiosResponse{
+		Zioses: []*vpsaobjectstorage.Zios{{Name: "store1", ObjectsCount: 78}},
+	}, nil)
+
+	filter, err := metrics.NewFilter(metrics.FilterConfig{Groups: map[string]bool{"policy": false, "ring_balance": false}})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	deadlines := metrics.NewScrapeDeadlines()
+
+	err = metrics.RegisterStorageMetricsWithMeter(meter, []*config.Target{
+		{Name: "London", CloudName: "cc1"},
+		{Name: "New York", CloudName: "cc2"},
+	},
+		metrics.WithClientFunc(func(_ context.Context, target *config.Target) metrics.ZadaraClient {
+			if target.Name == "New York" {
+				return slowZadaraClient{}
+			}
+
+			return fastClient
+		}),
+		metrics.WithFilter(filter),
+		metrics.WithScrapeDeadlines(deadlines),
+	)
+	require.NoError(t, err)
+
+	var (
+		rm         metricdata.ResourceMetrics
+		collectErr error
+	)
+
+	// Like the Prometheus exporter, the collection is not passed the context of the scrape request.
+	handler := metrics.ScrapeTimeoutHandler(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		collectErr = reader.Collect(context.Background(), &rm)
+	}), 0, deadlines)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set(metrics.ScrapeTimeoutHeader, "0.1")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	// The SDK does not wrap the errors of the callbacks.
+	require.ErrorContains(t, collectErr, metrics.ErrTargetTimeout.Error()+": New York")
+
+	values := map[string]float64{}
+
+	for _, sample := range metrics.Samples("zadara", &rm) {
+		values[sample.Name+" "+sample.Labels["name"]] = sample.Value
+	}
+
+	assert.Equal(t, map[string]float64{
+		"zadara_objects London":              78,
+		"zadara_accounts London":             0,
+		"zadara_users London":                0,
+		"zadara_containers London":           0,
+		"zadara_drives London":               0,
+		"zadara_cache London":                0,
+		"zadara_target_up London":            1,
+		"zadara_target_timed_out London":     0,
+		"zadara_target_auth_failed London":   0,
+		"zadara_target_up New York":          0,
+		"zadara_target_timed_out New York":   1,
+		"zadara_target_auth_failed New York": 0,
+	}, values)
+}
+
+func TestStorageMetricsScrapeTimeout_DiscoveredClouds(t *testing.T) {
+	t.Parallel()
+
+	filter, err := metrics.NewFilter(metrics.FilterConfig{Groups: map[string]bool{"policy": false, "ring_balance": false}})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	deadlines := metrics.NewScrapeDeadlines()
+	client := &slowCloudsClient{}
+
+	err = metrics.RegisterStorageMetricsWithMeter(meter, []*config.Target{
+		{Name: "London", DiscoverClouds: config.CloudDiscovery{Enabled: true}},
+	},
+		metrics.WithClientFunc(func(_ context.Context, _ *config.Target) metrics.ZadaraClient {
+			return client
+		}),
+		metrics.WithFilter(filter),
+		metrics.WithScrapeDeadlines(deadlines),
+	)
+	require.NoError(t, err)
+
+	// collect returns the status of each cloud after a scrape with a short timeout.
+	collect := func() map[string]float64 {
+		var (
+			rm         metricdata.ResourceMetrics
+			collectErr error
+		)
+
+		handler := metrics.ScrapeTimeoutHandler(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			collectErr = reader.Collect(context.Background(), &rm)
+		}), 0, deadlines)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set(metrics.ScrapeTimeoutHeader, "0.1")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		require.ErrorContains(t, collectErr, metrics.ErrTargetTimeout.Error())
+
+		values := map[string]float64{}
+
+		for _, sample := range metrics.Samples("zadara", &rm) {
+			if sample.Name == "zadara_target_up" || sample.Name == "zadara_target_timed_out" {
+				values[sample.Name+" "+sample.Labels["cloud_name"]] = sample.Value
+			}
+		}
+
+		return values
+	}
+
+	// The clouds are collected concurrently, and only the slow cloud times out.
+	assert.Equal(t, map[string]float64{
+		"zadara_target_up cc1":        1,
+		"zadara_target_timed_out cc1": 0,
+		"zadara_target_up cc2":        0,
+		"zadara_target_timed_out cc2": 1,
+	}, collect())
+
+	// When discovering the clouds times out, the clouds discovered before time out.
+	assert.Equal(t, map[string]float64{
+		"zadara_target_up cc1":        0,
+		"zadara_target_timed_out cc1": 1,
+		"zadara_target_up cc2":        0,
+		"zadara_target_timed_out cc2": 1,
+	}, collect())
+}
@@ -0,0 +1,277 @@
+package metrics
+
+import "strings"
+
+type (
+	// Level is the level of the Zadara hierarchy a metric is observed at.
+	Level string
+
+	// Definition describes a storage metric.
+	Definition struct {
+		// Name is the name of the metric, without the namespace.
+		Name string
+
+		// Description is the help text of the metric.
+		Description string
+
+		// Unit is the UCUM unit of the metric values, or empty if the values are unitless.
+		// It is set on the instruments, and the names already end with the Prometheus suffix for the unit,
+		// so the Prometheus exporter does not append it again.
+		Unit string
+
+		// LegacyName is the name the metric was exported with before the metrics followed the Prometheus
+		// naming conventions, or empty if the name is unchanged. Legacy ratio metrics are percentages.
+		LegacyName string
+
+		// LegacyDescription is the help text the metric was exported with under its legacy name.
+		LegacyDescription string
+
+		// Level is the level the metric is observed at.
+		Level Level
+
+		// Group is the group of metrics the metric belongs to.
+		Group string
+	}
+)
+
+// The levels metrics are observed at.
+const (
+	// StoreLevel metrics are labelled with name, cloud_name, store_name and store.
+	StoreLevel Level = "store"
+
+	// PolicyLevel metrics are additionally labelled with policy_name.
+	PolicyLevel Level = "policy"
+)
+
+// The groups of metrics.
+const (
+	StoreGroup       = "store"
+	PolicyGroup      = "policy"
+	RingBalanceGroup = "ring_balance"
+	ForecastGroup    = "forecast"
+)
+
+// The names of the storage metrics, without the namespace.
+const (
+	AccountsName                 = "accounts"
+	UsersName                    = "users"
+	ContainersName               = "containers"
+	ObjectsName                  = "objects"
+	DrivesName                   = "drives"
+	CacheName                    = "cache"
+	FreeStorageName              = "free_storage_bytes"
+	UsedStorageName              = "used_storage_bytes"
+	HealthRatioName              = "health_ratio"
+	RebalanceRatioName           = "rebalance_ratio"
+	DrivesAddedRatioName         = "drives_added_ratio"
+	RingBalanceNormalRatioName   = "ring_balance_normal_ratio"
+	RingBalanceDegradedRatioName = "ring_balance_degraded_ratio"
+	RingBalanceCriticalRatioName = "ring_balance_critical_ratio"
+	RingBalanceNormalName        = "ring_balance_normal"
+	RingBalanceDegradedName      = "ring_balance_degraded"
+	RingBalanceCriticalName      = "ring_balance_critical"
+	GrowthBytesPerDayName        = "growth_bytes_per_day"
+	PredictedFullTimestampName   = "predicted_full_timestamp_seconds"
+)
+
+// The names of the metrics reporting the status of the targets, which are not storage metrics
+// and cannot be filtered out.
+const (
+	TargetUpName         = "target_up"
+	TargetTimedOutName   = "target_timed_out"
+	TargetAuthFailedName = "target_auth_failed"
+)
+
+// Definitions returns the definitions of all the storage metrics, in the order they are observed.
+//
+//nolint:funlen // a flat table of definitions
+func Definitions() []Definition {
+	return []Definition{
+		{
+			Name:              AccountsName,
+			Description:       "The number of accounts in the Zadara store.",
+			Unit:              "{account}",
+			LegacyName:        "accounts_count",
+			LegacyDescription: "The number of accounts in the Zadara store.",
+			Level:             StoreLevel,
+			Group:             StoreGroup,
+		},
+		{
+			Name:              UsersName,
+			Description:       "The number of users in the Zadara store.",
+			Unit:              "{user}",
+			LegacyName:        "users_count",
+			LegacyDescription: "The number of users in the Zadara store.",
+			Level:             StoreLevel,
+			Group:             StoreGroup,
+		},
+		{
+			Name:              ContainersName,
+			Description:       "The number of containers in the Zadara store.",
+			Unit:              "{container}",
+			LegacyName:        "containers_count",
+			LegacyDescription: "The number of containers in the Zadara store.",
+			Level:             StoreLevel,
+			Group:             StoreGroup,
+		},
+		{
+			Name:              ObjectsName,
+			Description:       "The number of objects in the Zadara store.",
+			Unit:              "{object}",
+			LegacyName:        "objects_count",
+			LegacyDescription: "The number of objects in the Zadara store.",
+			Level:             StoreLevel,
+			Group:             StoreGroup,
+		},
+		{
+			Name:              DrivesName,
+			Description:       "The number of drives in the Zadara store.",
+			Unit:              "{drive}",
+			LegacyName:        "drives_count",
+			LegacyDescription: "The number of drives in the Zadara store.",
+			Level:             StoreLevel,
+			Group:             StoreGroup,
+		},
+		{
+			Name:        CacheName,
+			Description: "The amount of cache in the Zadara store.",
+			Level:       StoreLevel,
+			Group:       StoreGroup,
+		},
+		{
+			Name:              FreeStorageName,
+			Description:       "The amount of free storage in the Zadara store storage policy.",
+			Unit:              "By",
+			LegacyName:        "free_storage",
+			LegacyDescription: "The amount of free storage in the Zadara store storage policy.",
+			Level:             PolicyLevel,
+			Group:             PolicyGroup,
+		},
+		{
+			Name:              UsedStorageName,
+			Description:       "The amount of used storage in the Zadara store storage policy.",
+			Unit:              "By",
+			LegacyName:        "used_storage",
+			LegacyDescription: "The amount of used storage in the Zadara store storage policy.",
+			Level:             PolicyLevel,
+			Group:             PolicyGroup,
+		},
+		{
+			Name:              HealthRatioName,
+			Description:       "The ratio of health of the Zadara store storage policy.",
+			Unit:              "1",
+			LegacyName:        "health_percentage",
+			LegacyDescription: "The percentage of health in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             PolicyGroup,
+		},
+		{
+			Name:              RebalanceRatioName,
+			Description:       "The ratio of rebalance of the Zadara store storage policy.",
+			Unit:              "1",
+			LegacyName:        "rebalance_percentage",
+			LegacyDescription: "The percentage of rebalance in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             PolicyGroup,
+		},
+		{
+			Name:              DrivesAddedRatioName,
+			Description:       "The ratio of drives added to the Zadara store storage policy.",
+			Unit:              "1",
+			LegacyName:        "percentage_drives_added",
+			LegacyDescription: "The percentage of drives added in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             PolicyGroup,
+		},
+		{
+			Name:              RingBalanceNormalRatioName,
+			Description:       "The ratio of the ring of the Zadara store storage policy with a normal balance.",
+			Unit:              "1",
+			LegacyName:        "ring_balance_normal_percentage",
+			LegacyDescription: "The percentage of normal ring balance in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             RingBalanceGroup,
+		},
+		{
+			Name:              RingBalanceDegradedRatioName,
+			Description:       "The ratio of the ring of the Zadara store storage policy with a degraded balance.",
+			Unit:              "1",
+			LegacyName:        "ring_balance_Degraded_percentage",
+			LegacyDescription: "The percentage of Degraded ring balance in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             RingBalanceGroup,
+		},
+		{
+			Name:              RingBalanceCriticalRatioName,
+			Description:       "The ratio of the ring of the Zadara store storage policy with a critical balance.",
+			Unit:              "1",
+			LegacyName:        "ring_balance_critical_percentage",
+			LegacyDescription: "The percentage of critical ring balance in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             RingBalanceGroup,
+		},
+		{
+			Name:              RingBalanceNormalName,
+			Description:       "The count of normal ring balance in the Zadara store storage policy.",
+			LegacyName:        "ring_balance_normal_count",
+			LegacyDescription: "The count of normal ring balance in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             RingBalanceGroup,
+		},
+		{
+			Name:              RingBalanceDegradedName,
+			Description:       "The count of degraded ring balance in the Zadara store storage policy.",
+			LegacyName:        "ring_balance_Degraded_count",
+			LegacyDescription: "The count of Degraded ring balance in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             RingBalanceGroup,
+		},
+		{
+			Name:              RingBalanceCriticalName,
+			Description:       "The count of critical ring balance in the Zadara store storage policy.",
+			LegacyName:        "ring_balance_critical_count",
+			LegacyDescription: "The count of critical ring balance in the Zadara store.",
+			Level:             PolicyLevel,
+			Group:             RingBalanceGroup,
+		},
+		{
+			Name:        GrowthBytesPerDayName,
+			Description: "The forecast daily growth of the used storage in the Zadara store storage policy.",
+			Unit:        "By/d",
+			Level:       PolicyLevel,
+			Group:       ForecastGroup,
+		},
+		{
+			Name:        PredictedFullTimestampName,
+			Description: "The forecast unix timestamp at which the Zadara store storage policy will be full.",
+			Unit:        "s",
+			Level:       PolicyLevel,
+			Group:       ForecastGroup,
+		},
+	}
+}
+
+// LookupDefinition returns the definition of the metric with the given name.
+func LookupDefinition(name string) (Definition, bool) {
+	for _, def := range Definitions() {
+		if def.Name == name {
+			return def, true
+		}
+	}
+
+	return Definition{}, false
+}
+
+// PrometheusName returns the name of the metric as exposed by the Prometheus exporter
+// for the given namespace.
+func PrometheusName(namespace, name string) string {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	if !strings.HasSuffix(namespace, "_") {
+		namespace += "_"
+	}
+
+	return namespace + name
+}
@@ -1,11 +1,37 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"github.com/krystal/zadara-exporter/remotewrite"
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+type (
+	// ExporterConfig represents the configuration of the metric exporters.
+	ExporterConfig struct {
+		// Namespace is the namespace of the metrics exposed to Prometheus.
+		Namespace string
+
+		// Prometheus enables the Prometheus exporter, served by the metrics handler.
+		Prometheus bool
+
+		// OTLP configures pushing the metrics to an OTLP endpoint.
+		OTLP OTLPConfig
+
+		// RemoteWrite configures pushing the metrics to a Prometheus remote-write endpoint.
+		RemoteWrite remotewrite.Config
+	}
+
+	// ShutdownFunc shuts down the exporters, flushing any pending metrics.
+	ShutdownFunc func(ctx context.Context) error
 )
 
 const (
@@ -14,27 +40,147 @@ const (
 
 	// DefaultPath is the default path for the Prometheus exporter.
 	DefaultPath = "/metrics"
+
+	serviceName = "zadara-exporter"
 )
 
-// SetupPrometheusExporter initialises and sets up the Prometheus exporter for metrics.
-// It creates a new Prometheus exporter, sets it as the meter provider, and returns any error encountered.
-func SetupPrometheusExporter(namespace string) error {
+// ErrNoExporters is returned when none of the exporters are enabled.
+var ErrNoExporters = errors.New("no metric exporters are enabled")
+
+// newPrometheusReader creates a reader that exposes the metrics on the Prometheus registerer.
+func newPrometheusReader(namespace string, registerer promclient.Registerer) (metric.Reader, error) {
 	if namespace == "" {
 		namespace = DefaultNamespace
 	}
 
 	exporter, err := prometheus.New(
 		prometheus.WithNamespace(namespace),
+		prometheus.WithRegisterer(registerer),
 		prometheus.WithoutScopeInfo(),
 		prometheus.WithoutTargetInfo(),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create prometheus exporter: %w", err)
+		return nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+// SetupExporters initialises the enabled metric exporters and sets them up on the global meter provider.
+// It returns a function that shuts down the meter provider, which should be called before exiting
+// so that the push based exporters can flush their pending metrics.
+// If an exporter cannot be set up, the readers of those set up before it are shut down.
+func SetupExporters(ctx context.Context, config ExporterConfig) (ShutdownFunc, error) {
+	var readers []metric.Reader
+
+	// fail shuts down the readers created so far, so they do not leak when a later exporter fails.
+	fail := func(err error) (ShutdownFunc, error) {
+		return nil, errors.Join(err, shutdownReaders(ctx, readers))
+	}
+
+	if config.Prometheus {
+		reader, err := newPrometheusReader(config.Namespace, promclient.DefaultRegisterer)
+		if err != nil {
+			return fail(err)
+		}
+
+		readers = append(readers, reader)
+	}
+
+	if config.OTLP.Enabled {
+		reader, err := NewOTLPReader(ctx, config.OTLP)
+		if err != nil {
+			return fail(err)
+		}
+
+		readers = append(readers, reader)
+	}
+
+	var sender *remotewrite.Sender
+
+	if config.RemoteWrite.Enabled {
+		// The sender gathers from its own registry so it works without the Prometheus exporter,
+		// with the same metric names as scraping the exporter would.
+		registry := promclient.NewRegistry()
+
+		reader, err := newPrometheusReader(config.Namespace, registry)
+		if err != nil {
+			return fail(err)
+		}
+
+		readers = append(readers, reader)
+
+		sender, err = remotewrite.New(config.RemoteWrite, registry)
+		if err != nil {
+			return fail(fmt.Errorf("failed to create remote-write sender: %w", err))
+		}
+	}
+
+	if len(readers) == 0 {
+		return nil, ErrNoExporters
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return fail(fmt.Errorf("failed to create resource: %w", err))
 	}
 
-	provider := metric.NewMeterProvider(metric.WithReader(exporter))
+	opts := []metric.Option{metric.WithResource(res)}
+	for _, reader := range readers {
+		opts = append(opts, metric.WithReader(reader))
+	}
+
+	provider := metric.NewMeterProvider(opts...)
 
 	otel.SetMeterProvider(provider)
 
-	return nil
+	if sender == nil {
+		return provider.Shutdown, nil
+	}
+
+	return runRemoteWrite(sender, provider), nil
+}
+
+// shutdownReaders shuts down the readers that were not registered with a meter provider.
+func shutdownReaders(ctx context.Context, readers []metric.Reader) error {
+	var errs []error
+
+	for _, reader := range readers {
+		if err := reader.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down metric reader: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runRemoteWrite runs the remote-write sender in the background.
+// It returns a function that stops the sender, sends any queued requests and shuts down the meter provider.
+func runRemoteWrite(sender *remotewrite.Sender, provider *metric.MeterProvider) ShutdownFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		sender.Run(ctx)
+	}()
+
+	return func(ctx context.Context) error {
+		cancel()
+		<-done
+
+		var errs []error
+
+		if err := sender.Flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush remote-write queue: %w", err))
+		}
+
+		if err := provider.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down meter provider: %w", err))
+		}
+
+		return errors.Join(errs...)
+	}
 }
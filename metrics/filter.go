@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+type (
+	// FilterConfig configures which storage metrics are collected.
+	FilterConfig struct {
+		// Groups enables or disables the groups of metrics by name. Groups that are not listed are enabled.
+		Groups map[string]bool `mapstructure:"groups"`
+
+		// Allow is the regular expressions matching the names of the metrics to collect, without the namespace.
+		// All metrics are allowed if empty.
+		Allow []string `mapstructure:"allow"`
+
+		// Deny is the regular expressions matching the names of the metrics not to collect, without the namespace.
+		Deny []string `mapstructure:"deny"`
+	}
+
+	// Filter decides which storage metrics are collected.
+	// A nil Filter collects all the metrics.
+	Filter struct {
+		groups map[string]bool
+		allow  []*regexp.Regexp
+		deny   []*regexp.Regexp
+	}
+)
+
+var (
+	// ErrUnknownGroup is returned when enabling or disabling a group of metrics that does not exist.
+	ErrUnknownGroup = errors.New("unknown metric group")
+
+	// ErrInvalidPattern is returned when an allow or deny pattern is not a valid regular expression.
+	ErrInvalidPattern = errors.New("invalid metric name pattern")
+)
+
+// Groups returns the names of the groups of metrics, in the order they are observed.
+func Groups() []string {
+	var groups []string
+
+	for _, def := range Definitions() {
+		if !slices.Contains(groups, def.Group) {
+			groups = append(groups, def.Group)
+		}
+	}
+
+	return groups
+}
+
+// compilePatterns compiles the patterns, anchored to match the whole metric name like Prometheus relabelling.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// NewFilter creates a filter from the configuration.
+// It returns an error if a group does not exist or a pattern is not a valid regular expression.
+func NewFilter(config FilterConfig) (*Filter, error) {
+	groups := Groups()
+
+	for group := range config.Groups {
+		if !slices.Contains(groups, group) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownGroup, group)
+		}
+	}
+
+	allow, err := compilePatterns(config.Allow)
+	if err != nil {
+		return nil, err
+	}
+
+	deny, err := compilePatterns(config.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{groups: config.Groups, allow: allow, deny: deny}, nil
+}
+
+// Enabled reports whether the metric is collected: its group is enabled, its name matches an allow pattern
+// if there are any, and it matches no deny pattern.
+func (f *Filter) Enabled(def Definition) bool {
+	if f == nil {
+		return true
+	}
+
+	if enabled, ok := f.groups[def.Group]; ok && !enabled {
+		return false
+	}
+
+	if len(f.allow) > 0 && !slices.ContainsFunc(f.allow, func(re *regexp.Regexp) bool {
+		return re.MatchString(def.Name)
+	}) {
+		return false
+	}
+
+	return !slices.ContainsFunc(f.deny, func(re *regexp.Regexp) bool {
+		return re.MatchString(def.Name)
+	})
+}
@@ -0,0 +1,146 @@
+package metrics_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestNewFilter(t *testing.T) {
+	t.Parallel()
+
+	_, err := metrics.NewFilter(metrics.FilterConfig{Groups: map[string]bool{"volumes": false}})
+	require.ErrorIs(t, err, metrics.ErrUnknownGroup)
+
+	_, err = metrics.NewFilter(metrics.FilterConfig{Allow: []string{"health_("}})
+	require.ErrorIs(t, err, metrics.ErrInvalidPattern)
+
+	_, err = metrics.NewFilter(metrics.FilterConfig{Deny: []string{"*"}})
+	require.ErrorIs(t, err, metrics.ErrInvalidPattern)
+}
+
+func TestFilterEnabled(t *testing.T) {
+	t.Parallel()
+
+	health, _ := metrics.LookupDefinition(metrics.HealthRatioName)
+	objects, _ := metrics.LookupDefinition(metrics.ObjectsName)
+	degraded, _ := metrics.LookupDefinition(metrics.RingBalanceDegradedRatioName)
+
+	tests := []struct {
+		name   string
+		config *metrics.FilterConfig
+		want   map[string]bool
+	}{
+		{
+			name: "nil",
+			want: map[string]bool{health.Name: true, objects.Name: true, degraded.Name: true},
+		},
+		{
+			name:   "empty",
+			config: &metrics.FilterConfig{},
+			want:   map[string]bool{health.Name: true, objects.Name: true, degraded.Name: true},
+		},
+		{
+			name:   "groups",
+			config: &metrics.FilterConfig{Groups: map[string]bool{"store": false, "ring_balance": true}},
+			want:   map[string]bool{health.Name: true, objects.Name: false, degraded.Name: true},
+		},
+		{
+			name:   "allow",
+			config: &metrics.FilterConfig{Allow: []string{".*_ratio"}},
+			want:   map[string]bool{health.Name: true, objects.Name: false, degraded.Name: true},
+		},
+		{
+			name:   "allow anchored",
+			config: &metrics.FilterConfig{Allow: []string{"ratio", "objects"}},
+			want:   map[string]bool{health.Name: false, objects.Name: true, degraded.Name: false},
+		},
+		{
+			name:   "deny",
+			config: &metrics.FilterConfig{Allow: []string{".*_ratio"}, Deny: []string{"ring_balance_.*"}},
+			want:   map[string]bool{health.Name: true, objects.Name: false, degraded.Name: false},
+		},
+		{
+			name:   "group disabled before allow",
+			config: &metrics.FilterConfig{Groups: map[string]bool{"policy": false}, Allow: []string{"health_ratio"}},
+			want:   map[string]bool{health.Name: false, objects.Name: false, degraded.Name: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var filter *metrics.Filter
+
+			if tt.config != nil {
+				var err error
+
+				filter, err = metrics.NewFilter(*tt.config)
+				require.NoError(t, err)
+			}
+
+			for _, def := range []metrics.Definition{health, objects, degraded} {
+				assert.Equal(t, tt.want[def.Name], filter.Enabled(def), def.Name)
+			}
+		})
+	}
+}
+
+func TestStorageMetricsFilter(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(mockZadaraClient)
+	mockClient.On("GetStores", mock.Anything, "cc1").Return(&vpsaobjectstorage.ZiosResponse{
+		Zioses: []*vpsaobjectstorage.Zios{{Name: "store1", ObjectsCount: 78, UsersCount: 5, Cache: 1670}},
+	}, nil)
+
+	filter, err := metrics.NewFilter(metrics.FilterConfig{
+		Groups: map[string]bool{"policy": false, "ring_balance": false},
+		Deny:   []string{"cache"},
+	})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	err = metrics.RegisterStorageMetricsWithMeter(meter, []*config.Target{{Name: "London", CloudName: "cc1"}},
+		metrics.WithClientFunc(func(_ context.Context, _ *config.Target) metrics.ZadaraClient {
+			return mockClient
+		}),
+		metrics.WithFilter(filter),
+		metrics.WithLegacyNames(),
+	)
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	var names []string
+	for _, sample := range metrics.Samples("zadara", &rm) {
+		names = append(names, sample.Name)
+	}
+
+	sort.Strings(names)
+
+	assert.Equal(t, []string{
+		"zadara_accounts", "zadara_accounts_count",
+		"zadara_containers", "zadara_containers_count",
+		"zadara_drives", "zadara_drives_count",
+		"zadara_objects", "zadara_objects_count",
+		"zadara_target_auth_failed", "zadara_target_timed_out", "zadara_target_up",
+		"zadara_users", "zadara_users_count",
+	}, names)
+
+	// The storage policies are not requested when no policy metrics are collected.
+	mockClient.AssertNotCalled(t, "GetAllStoragePolicies", mock.Anything)
+}
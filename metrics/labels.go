@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/krystal/zadara-exporter/config"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type (
+	// LabelConfig configures the built-in labels of the storage and client metrics.
+	LabelConfig struct {
+		// Drop is the built-in labels to leave out of the metrics.
+		Drop []string `mapstructure:"drop"`
+
+		// Rename maps built-in labels to the names they are exported with.
+		Rename map[string]string `mapstructure:"rename"`
+	}
+)
+
+// The built-in labels of the storage and client metrics.
+const (
+	NameLabel       = "name"
+	CloudNameLabel  = "cloud_name"
+	StoreNameLabel  = "store_name"
+	StoreLabel      = "store"
+	PolicyNameLabel = "policy_name"
+
+	// CommandCenterLabel is the host of the Command Center, which labels the client metrics.
+	CommandCenterLabel = "command_center"
+)
+
+var (
+	// ErrInvalidLabelName is returned when a label name is not a valid Prometheus label name.
+	ErrInvalidLabelName = errors.New("invalid label name")
+
+	// ErrUnknownLabel is returned when dropping or renaming a label that is not a built-in label.
+	ErrUnknownLabel = errors.New("unknown built-in label")
+
+	// ErrDuplicateLabel is returned when two labels of the metrics would have the same name.
+	ErrDuplicateLabel = errors.New("duplicate label")
+)
+
+//nolint:gochecknoglobals // the Prometheus label name syntax
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// BuiltinLabels returns the names of the built-in labels of the storage and client metrics.
+func BuiltinLabels() []string {
+	return []string{NameLabel, CloudNameLabel, StoreNameLabel, StoreLabel, PolicyNameLabel, CommandCenterLabel}
+}
+
+// validLabelName reports whether the name is a valid Prometheus label name,
+// which does not start with the reserved double underscore.
+func validLabelName(name string) bool {
+	return labelNameRegexp.MatchString(name) && (len(name) < 2 || name[:2] != "__")
+}
+
+// LabelName returns the name the built-in label is exported with, and false if it is dropped.
+func (c LabelConfig) LabelName(name string) (string, bool) {
+	if slices.Contains(c.Drop, name) {
+		return "", false
+	}
+
+	if renamed, ok := c.Rename[name]; ok {
+		return renamed, true
+	}
+
+	return name, true
+}
+
+// LabelNames returns the names the built-in labels are exported with, leaving out the dropped ones.
+func (c LabelConfig) LabelNames(names ...string) []string {
+	exported := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if name, ok := c.LabelName(name); ok {
+			exported = append(exported, name)
+		}
+	}
+
+	return exported
+}
+
+// Validate checks that the dropped and renamed labels are built-in labels, that the label names are valid,
+// and that no two labels of the metrics of a target have the same name.
+func (c LabelConfig) Validate(targets []*config.Target) error {
+	builtin := BuiltinLabels()
+
+	for _, name := range c.Drop {
+		if !slices.Contains(builtin, name) {
+			return fmt.Errorf("%w: cannot drop %q", ErrUnknownLabel, name)
+		}
+	}
+
+	names := map[string]bool{}
+
+	for _, name := range builtin {
+		if _, ok := c.Rename[name]; ok && slices.Contains(c.Drop, name) {
+			return fmt.Errorf("%w: %q is both dropped and renamed", ErrDuplicateLabel, name)
+		}
+
+		exported, ok := c.LabelName(name)
+		if !ok {
+			continue
+		}
+
+		if !validLabelName(exported) {
+			return fmt.Errorf("%w: %q", ErrInvalidLabelName, exported)
+		}
+
+		if names[exported] {
+			return fmt.Errorf("%w: %q", ErrDuplicateLabel, exported)
+		}
+
+		names[exported] = true
+	}
+
+	for name := range c.Rename {
+		if !slices.Contains(builtin, name) {
+			return fmt.Errorf("%w: cannot rename %q", ErrUnknownLabel, name)
+		}
+	}
+
+	for _, target := range targets {
+		for name := range target.Labels {
+			if !validLabelName(name) {
+				return fmt.Errorf("%w: %q of target %s", ErrInvalidLabelName, name, target.Name)
+			}
+
+			if names[name] {
+				return fmt.Errorf("%w: %q of target %s is a built-in label", ErrDuplicateLabel, name, target.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// attributes returns the attributes of the target's metrics with the built-in labels, after dropping and
+// renaming them, followed by the static labels of the target.
+func (c LabelConfig) attributes(target *config.Target, builtin ...attribute.KeyValue) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(builtin)+len(target.Labels))
+
+	for _, kv := range builtin {
+		name, ok := c.LabelName(string(kv.Key))
+		if !ok {
+			continue
+		}
+
+		attrs = append(attrs, attribute.String(name, kv.Value.AsString()))
+	}
+
+	for name, value := range target.Labels {
+		attrs = append(attrs, attribute.String(name, value))
+	}
+
+	return attrs
+}
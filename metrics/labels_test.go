@@ -0,0 +1,154 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestLabelConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  metrics.LabelConfig
+		labels  map[string]string
+		wantErr error
+	}{
+		{
+			name:   "defaults",
+			labels: map[string]string{"region": "eu-west", "_environment": "production"},
+		},
+		{
+			name:   "drop and rename",
+			config: metrics.LabelConfig{Drop: []string{"store_name"}, Rename: map[string]string{"name": "target"}},
+			labels: map[string]string{"name": "reused after renaming"},
+		},
+		{
+			name:    "drop unknown",
+			config:  metrics.LabelConfig{Drop: []string{"region"}},
+			wantErr: metrics.ErrUnknownLabel,
+		},
+		{
+			name:    "rename unknown",
+			config:  metrics.LabelConfig{Rename: map[string]string{"region": "zone"}},
+			wantErr: metrics.ErrUnknownLabel,
+		},
+		{
+			name:    "rename invalid",
+			config:  metrics.LabelConfig{Rename: map[string]string{"name": "target-name"}},
+			wantErr: metrics.ErrInvalidLabelName,
+		},
+		{
+			name:    "rename to built-in",
+			config:  metrics.LabelConfig{Rename: map[string]string{"store_name": "store"}},
+			wantErr: metrics.ErrDuplicateLabel,
+		},
+		{
+			name:    "rename dropped",
+			config:  metrics.LabelConfig{Drop: []string{"name"}, Rename: map[string]string{"name": "target"}},
+			wantErr: metrics.ErrDuplicateLabel,
+		},
+		{
+			name:    "static invalid",
+			labels:  map[string]string{"1region": "eu-west"},
+			wantErr: metrics.ErrInvalidLabelName,
+		},
+		{
+			name:    "static reserved",
+			labels:  map[string]string{"__name__": "free_storage"},
+			wantErr: metrics.ErrInvalidLabelName,
+		},
+		{
+			name:    "static built-in",
+			labels:  map[string]string{"cloud_name": "cc9"},
+			wantErr: metrics.ErrDuplicateLabel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.config.Validate([]*config.Target{{Name: "target", Labels: tt.labels}})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestStorageMetricsLabels(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(mockZadaraClient)
+	mockClient.On("GetAllStoragePolicies", mock.Anything).Return([]*commandcenter.StoreStoragePolicies{
+		{
+			Store: &vpsaobjectstorage.Zios{Name: "store1", ObjectsCount: 78},
+			Policies: []*vpsaobjectstorage.ZiosStoragePolicy{
+				{Name: "policy1", FreeCapacity: 100, PercentageDrivesAdded: "100"},
+			},
+		},
+	}, nil)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	target := &config.Target{Name: "London", CloudName: "cc1", Labels: map[string]string{"region": "eu-west"}}
+
+	err := metrics.RegisterStorageMetricsWithMeter(meter, []*config.Target{target},
+		metrics.WithClientFunc(func(_ context.Context, _ *config.Target) metrics.ZadaraClient {
+			return mockClient
+		}),
+		metrics.WithLabels(metrics.LabelConfig{
+			Drop:   []string{"store_name"},
+			Rename: map[string]string{"name": "target"},
+		}),
+	)
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	labels := map[string]map[string]string{}
+	for _, sample := range metrics.Samples("zadara", &rm) {
+		labels[sample.Name] = sample.Labels
+	}
+
+	assert.Equal(t, map[string]string{
+		"target":     "London",
+		"cloud_name": "cc1",
+		"store":      "store1@cc1",
+		"region":     "eu-west",
+	}, labels["zadara_objects"])
+	assert.Equal(t, map[string]string{
+		"target":      "London",
+		"cloud_name":  "cc1",
+		"store":       "store1@cc1",
+		"policy_name": "policy1",
+		"region":      "eu-west",
+	}, labels["zadara_free_storage_bytes"])
+}
+
+func TestRegisterStorageMetricsInvalidLabels(t *testing.T) {
+	t.Parallel()
+
+	meter := sdkmetric.NewMeterProvider().Meter("test")
+
+	err := metrics.RegisterStorageMetricsWithMeter(meter,
+		[]*config.Target{{Name: "London", Labels: map[string]string{"store": "london"}}})
+	require.ErrorIs(t, err, metrics.ErrDuplicateLabel)
+}
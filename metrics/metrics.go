@@ -4,9 +4,13 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync"
 
 	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/forecast"
 	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
 )
@@ -14,68 +18,182 @@ import (
 type (
 	// StorageMetrics provides the metrics for the storage.
 	StorageMetrics struct {
-		FreeStorage                   metric.Int64ObservableGauge
-		UsedStorage                   metric.Int64ObservableGauge
-		AccountsCount                 metric.Int64ObservableGauge
-		UsersCount                    metric.Int64ObservableGauge
-		ContainersCount               metric.Int64ObservableGauge
-		ObjectsCount                  metric.Int64ObservableGauge
-		DrivesCount                   metric.Int64ObservableGauge
-		Cache                         metric.Int64ObservableGauge
-		HealthPercentage              metric.Float64ObservableGauge
-		RebalancePercentage           metric.Float64ObservableGauge
-		PercentageDrivesAdded         metric.Float64ObservableGauge
-		RingBalanceNormalPercentage   metric.Float64ObservableGauge
-		RingBalanceDegradedPercentage metric.Float64ObservableGauge
-		RingBalanceCriticalPercentage metric.Float64ObservableGauge
-		RingBalanceNormalCount        metric.Int64ObservableGauge
-		RingBalanceDegradedCount      metric.Int64ObservableGauge
-		RingBalanceCriticalCount      metric.Int64ObservableGauge
+		FreeStorage              metric.Int64ObservableGauge
+		UsedStorage              metric.Int64ObservableGauge
+		AccountsCount            metric.Int64ObservableGauge
+		UsersCount               metric.Int64ObservableGauge
+		ContainersCount          metric.Int64ObservableGauge
+		ObjectsCount             metric.Int64ObservableGauge
+		DrivesCount              metric.Int64ObservableGauge
+		Cache                    metric.Int64ObservableGauge
+		HealthRatio              metric.Float64ObservableGauge
+		RebalanceRatio           metric.Float64ObservableGauge
+		DrivesAddedRatio         metric.Float64ObservableGauge
+		RingBalanceNormalRatio   metric.Float64ObservableGauge
+		RingBalanceDegradedRatio metric.Float64ObservableGauge
+		RingBalanceCriticalRatio metric.Float64ObservableGauge
+		RingBalanceNormalCount   metric.Int64ObservableGauge
+		RingBalanceDegradedCount metric.Int64ObservableGauge
+		RingBalanceCriticalCount metric.Int64ObservableGauge
+		GrowthBytesPerDay        metric.Float64ObservableGauge
+		PredictedFullTimestamp   metric.Float64ObservableGauge
+		TargetUp                 metric.Int64ObservableGauge
+		TargetTimedOut           metric.Int64ObservableGauge
+		TargetAuthFailed         metric.Int64ObservableGauge
+
+		forecaster  *forecast.Forecaster
+		deadlines   *ScrapeDeadlines
+		newClient   ClientFunc
+		targets     TargetsFunc
+		legacyNames bool
+		labels      LabelConfig
+		filter      *Filter
+
+		// policyMetrics is whether any metrics are observed from the storage policies.
+		policyMetrics bool
+
+		// instruments are all the instruments observed by the callback, including the legacy ones.
+		instruments []metric.Observable
+
+		// legacyInt64 and legacyFloat64 are the instruments with the legacy names, by the current names.
+		legacyInt64   map[string]metric.Int64ObservableGauge
+		legacyFloat64 map[string]metric.Float64ObservableGauge
+
+		// clouds are the targets of the clouds last discovered for each target, by target name,
+		// so their status can be reported when discovering them times out.
+		cloudsMu sync.Mutex
+		clouds   map[string][]*config.Target
 	}
 
+	// Option configures the StorageMetrics.
+	Option func(*StorageMetrics)
+
 	// ZadaraClient provides the client for the Zadara storage.
 	ZadaraClient interface {
 		GetAllStoragePolicies(ctx context.Context) ([]*commandcenter.StoreStoragePolicies, error)
+		GetStores(ctx context.Context, cloudName string) (*vpsaobjectstorage.ZiosResponse, error)
 	}
 )
 
+// meterName is the name of the meter the storage metrics are registered with.
+const meterName = "zadara"
+
+// int64Gauge creates an int64 observable gauge for the metric with the given name,
+// and one with its legacy name if enabled. The description and unit are taken from the metric's definition.
+// It returns a nil gauge if the metric is filtered out.
+func (sm *StorageMetrics) int64Gauge(meter metric.Meter, name string) (metric.Int64ObservableGauge, error) {
+	def, _ := LookupDefinition(name)
+	if !sm.filter.Enabled(def) {
+		return nil, nil //nolint:nilnil // a nil gauge is not observed
+	}
+
+	gauge, err := meter.Int64ObservableGauge(name, metric.WithDescription(def.Description), metric.WithUnit(def.Unit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s gauge: %w", name, err)
+	}
+
+	sm.instruments = append(sm.instruments, gauge)
+
+	if sm.legacyNames && def.LegacyName != "" {
+		legacy, err := meter.Int64ObservableGauge(def.LegacyName, metric.WithDescription(def.LegacyDescription))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s gauge: %w", def.LegacyName, err)
+		}
+
+		sm.legacyInt64[name] = legacy
+		sm.instruments = append(sm.instruments, legacy)
+	}
+
+	return gauge, nil
+}
+
+// float64Gauge creates a float64 observable gauge for the metric with the given name,
+// and one with its legacy name if enabled. The description and unit are taken from the metric's definition.
+// It returns a nil gauge if the metric is filtered out.
+func (sm *StorageMetrics) float64Gauge(meter metric.Meter, name string) (metric.Float64ObservableGauge, error) {
+	def, _ := LookupDefinition(name)
+	if !sm.filter.Enabled(def) {
+		return nil, nil //nolint:nilnil // a nil gauge is not observed
+	}
+
+	gauge, err := meter.Float64ObservableGauge(name, metric.WithDescription(def.Description), metric.WithUnit(def.Unit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s gauge: %w", name, err)
+	}
+
+	sm.instruments = append(sm.instruments, gauge)
+
+	if sm.legacyNames && def.LegacyName != "" {
+		legacy, err := meter.Float64ObservableGauge(def.LegacyName, metric.WithDescription(def.LegacyDescription))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s gauge: %w", def.LegacyName, err)
+		}
+
+		sm.legacyFloat64[name] = legacy
+		sm.instruments = append(sm.instruments, legacy)
+	}
+
+	return gauge, nil
+}
+
+func targetMetrics(meter metric.Meter, storageMetrics *StorageMetrics) error {
+	var err error
+
+	storageMetrics.TargetUp, err = meter.Int64ObservableGauge(TargetUpName,
+		metric.WithDescription("Whether the storage metrics of the target were collected: 1 if so and 0 otherwise."))
+	if err != nil {
+		return fmt.Errorf("failed to create %s gauge: %w", TargetUpName, err)
+	}
+
+	storageMetrics.TargetTimedOut, err = meter.Int64ObservableGauge(TargetTimedOutName,
+		metric.WithDescription("Whether collecting the storage metrics of the target timed out: 1 if so and 0 otherwise."))
+	if err != nil {
+		return fmt.Errorf("failed to create %s gauge: %w", TargetTimedOutName, err)
+	}
+
+	storageMetrics.TargetAuthFailed, err = meter.Int64ObservableGauge(TargetAuthFailedName,
+		metric.WithDescription("Whether the Command Center rejected the token of the target: 1 if so and 0 otherwise."))
+	if err != nil {
+		return fmt.Errorf("failed to create %s gauge: %w", TargetAuthFailedName, err)
+	}
+
+	storageMetrics.instruments = append(storageMetrics.instruments,
+		storageMetrics.TargetUp, storageMetrics.TargetTimedOut, storageMetrics.TargetAuthFailed)
+
+	return nil
+}
+
 func storeMetrics(meter metric.Meter, storageMetrics *StorageMetrics) error {
 	var err error
 
-	storageMetrics.AccountsCount, err = meter.Int64ObservableGauge("accounts_count",
-		metric.WithDescription("The number of accounts in the Zadara store."))
+	storageMetrics.AccountsCount, err = storageMetrics.int64Gauge(meter, AccountsName)
 	if err != nil {
-		return fmt.Errorf("failed to create accounts count gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.UsersCount, err = meter.Int64ObservableGauge("users_count",
-		metric.WithDescription("The number of users in the Zadara store."))
+	storageMetrics.UsersCount, err = storageMetrics.int64Gauge(meter, UsersName)
 	if err != nil {
-		return fmt.Errorf("failed to create users count gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.ContainersCount, err = meter.Int64ObservableGauge("containers_count",
-		metric.WithDescription("The number of containers in the Zadara store."))
+	storageMetrics.ContainersCount, err = storageMetrics.int64Gauge(meter, ContainersName)
 	if err != nil {
-		return fmt.Errorf("failed to create containers count gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.ObjectsCount, err = meter.Int64ObservableGauge("objects_count",
-		metric.WithDescription("The number of objects in the Zadara store."))
+	storageMetrics.ObjectsCount, err = storageMetrics.int64Gauge(meter, ObjectsName)
 	if err != nil {
-		return fmt.Errorf("failed to create objects count gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.DrivesCount, err = meter.Int64ObservableGauge("drives_count",
-		metric.WithDescription("The number of drives in the Zadara store."))
+	storageMetrics.DrivesCount, err = storageMetrics.int64Gauge(meter, DrivesName)
 	if err != nil {
-		return fmt.Errorf("failed to create drives count gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.Cache, err = meter.Int64ObservableGauge("cache",
-		metric.WithDescription("The amount of cache in the Zadara store."))
+	storageMetrics.Cache, err = storageMetrics.int64Gauge(meter, CacheName)
 	if err != nil {
-		return fmt.Errorf("failed to create cache gauge: %w", err)
+		return err
 	}
 
 	return nil
@@ -84,34 +202,29 @@ func storeMetrics(meter metric.Meter, storageMetrics *StorageMetrics) error {
 func storagePolicyMetrics(meter metric.Meter, storageMetrics *StorageMetrics) error {
 	var err error
 
-	storageMetrics.FreeStorage, err = meter.Int64ObservableGauge("free_storage",
-		metric.WithDescription("The amount of free storage in the Zadara store storage policy."))
+	storageMetrics.FreeStorage, err = storageMetrics.int64Gauge(meter, FreeStorageName)
 	if err != nil {
-		return fmt.Errorf("failed to create free storage gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.UsedStorage, err = meter.Int64ObservableGauge("used_storage",
-		metric.WithDescription("The amount of used storage in the Zadara store storage policy."))
+	storageMetrics.UsedStorage, err = storageMetrics.int64Gauge(meter, UsedStorageName)
 	if err != nil {
-		return fmt.Errorf("failed to create used storage gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.HealthPercentage, err = meter.Float64ObservableGauge("health_percentage",
-		metric.WithDescription("The percentage of health in the Zadara store."))
+	storageMetrics.HealthRatio, err = storageMetrics.float64Gauge(meter, HealthRatioName)
 	if err != nil {
-		return fmt.Errorf("failed to create health percentage gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.RebalancePercentage, err = meter.Float64ObservableGauge("rebalance_percentage",
-		metric.WithDescription("The percentage of rebalance in the Zadara store."))
+	storageMetrics.RebalanceRatio, err = storageMetrics.float64Gauge(meter, RebalanceRatioName)
 	if err != nil {
-		return fmt.Errorf("failed to create rebalance percentage gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.PercentageDrivesAdded, err = meter.Float64ObservableGauge("percentage_drives_added",
-		metric.WithDescription("The percentage of drives added in the Zadara store."))
+	storageMetrics.DrivesAddedRatio, err = storageMetrics.float64Gauge(meter, DrivesAddedRatioName)
 	if err != nil {
-		return fmt.Errorf("failed to create percentage drives added gauge: %w", err)
+		return err
 	}
 
 	return nil
@@ -120,51 +233,140 @@ func storagePolicyMetrics(meter metric.Meter, storageMetrics *StorageMetrics) er
 func ringBalanceMetrics(meter metric.Meter, storageMetrics *StorageMetrics) error {
 	var err error
 
-	storageMetrics.RingBalanceNormalPercentage, err = meter.Float64ObservableGauge("ring_balance_normal_percentage",
-		metric.WithDescription("The percentage of normal ring balance in the Zadara store."))
+	storageMetrics.RingBalanceNormalRatio, err = storageMetrics.float64Gauge(meter, RingBalanceNormalRatioName)
+	if err != nil {
+		return err
+	}
+
+	storageMetrics.RingBalanceDegradedRatio, err = storageMetrics.float64Gauge(meter, RingBalanceDegradedRatioName)
 	if err != nil {
-		return fmt.Errorf("failed to create ring balance normal percentage gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.RingBalanceDegradedPercentage, err = meter.Float64ObservableGauge(
-		"ring_balance_Degraded_percentage",
-		metric.WithDescription("The percentage of Degraded ring balance in the Zadara store."))
+	storageMetrics.RingBalanceCriticalRatio, err = storageMetrics.float64Gauge(meter, RingBalanceCriticalRatioName)
 	if err != nil {
-		return fmt.Errorf("failed to create ring balance Degraded percentage gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.RingBalanceCriticalPercentage, err = meter.Float64ObservableGauge(
-		"ring_balance_critical_percentage",
-		metric.WithDescription("The percentage of critical ring balance in the Zadara store."))
+	storageMetrics.RingBalanceNormalCount, err = storageMetrics.int64Gauge(meter, RingBalanceNormalName)
 	if err != nil {
-		return fmt.Errorf("failed to create ring balance critical percentage gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.RingBalanceNormalCount, err = meter.Int64ObservableGauge("ring_balance_normal_count",
-		metric.WithDescription("The count of normal ring balance in the Zadara store."))
+	storageMetrics.RingBalanceDegradedCount, err = storageMetrics.int64Gauge(meter, RingBalanceDegradedName)
 	if err != nil {
-		return fmt.Errorf("failed to create ring balance normal count gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.RingBalanceDegradedCount, err = meter.Int64ObservableGauge("ring_balance_Degraded_count",
-		metric.WithDescription("The count of Degraded ring balance in the Zadara store."))
+	storageMetrics.RingBalanceCriticalCount, err = storageMetrics.int64Gauge(meter, RingBalanceCriticalName)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func forecastMetrics(meter metric.Meter, storageMetrics *StorageMetrics) error {
+	var err error
+
+	storageMetrics.GrowthBytesPerDay, err = storageMetrics.float64Gauge(meter, GrowthBytesPerDayName)
 	if err != nil {
-		return fmt.Errorf("failed to create ring balance Degraded count gauge: %w", err)
+		return err
 	}
 
-	storageMetrics.RingBalanceCriticalCount, err = meter.Int64ObservableGauge("ring_balance_critical_count",
-		metric.WithDescription("The count of critical ring balance in the Zadara store."))
+	storageMetrics.PredictedFullTimestamp, err = storageMetrics.float64Gauge(meter, PredictedFullTimestampName)
 	if err != nil {
-		return fmt.Errorf("failed to create ring balance critical count gauge: %w", err)
+		return err
 	}
 
 	return nil
 }
 
+// WithForecaster enables capacity forecasting of the storage policies using the provided forecaster.
+func WithForecaster(forecaster *forecast.Forecaster) Option {
+	return func(sm *StorageMetrics) {
+		sm.forecaster = forecaster
+	}
+}
+
+// WithLegacyNames also exports the metrics with the names they had before following the Prometheus naming
+// conventions, with percentages instead of ratios, so dashboards and alerts can be migrated.
+func WithLegacyNames() Option {
+	return func(sm *StorageMetrics) {
+		sm.legacyNames = true
+	}
+}
+
+// WithLabels drops and renames the built-in labels of the metrics.
+func WithLabels(labels LabelConfig) Option {
+	return func(sm *StorageMetrics) {
+		sm.labels = labels
+	}
+}
+
+// WithFilter only collects the metrics enabled by the filter.
+// The Command Center API is not called for the storage policies if no policy metrics are enabled.
+func WithFilter(filter *Filter) Option {
+	return func(sm *StorageMetrics) {
+		sm.filter = filter
+	}
+}
+
+// WithScrapeDeadlines stops collecting the metrics at the earliest deadline of the in-flight scrapes.
+// The targets that have not been collected by then are marked as timed out.
+func WithScrapeDeadlines(deadlines *ScrapeDeadlines) Option {
+	return func(sm *StorageMetrics) {
+		sm.deadlines = deadlines
+	}
+}
+
+// WithClientFunc sets the function creating the client for each target,
+// defaulting to a Command Center client with the default options.
+func WithClientFunc(newClient ClientFunc) Option {
+	return func(sm *StorageMetrics) {
+		sm.newClient = newClient
+	}
+}
+
+// WithTargetsFunc collects the metrics of the targets returned by the function on each collection,
+// in place of the registered targets, so targets can be added and removed while the exporter runs.
+func WithTargetsFunc(targets TargetsFunc) Option {
+	return func(sm *StorageMetrics) {
+		sm.targets = targets
+	}
+}
+
+// needsPolicies reports whether any of the collected metrics are observed from the storage policies.
+func (sm *StorageMetrics) needsPolicies() bool {
+	for _, def := range Definitions() {
+		if def.Level != PolicyLevel || !sm.filter.Enabled(def) {
+			continue
+		}
+
+		if def.Group == ForecastGroup && sm.forecaster == nil {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
 // NewStorageMetrics creates a new instance of StorageMetrics using the provided meter.
 // It returns a pointer to the created StorageMetrics and an error, if any.
-func NewStorageMetrics(meter metric.Meter) (*StorageMetrics, error) {
-	storageMetrics := &StorageMetrics{}
+func NewStorageMetrics(meter metric.Meter, opts ...Option) (*StorageMetrics, error) {
+	storageMetrics := &StorageMetrics{
+		newClient: func(_ context.Context, target *config.Target) ZadaraClient {
+			return commandcenter.NewClient(target)
+		},
+		legacyInt64:   map[string]metric.Int64ObservableGauge{},
+		legacyFloat64: map[string]metric.Float64ObservableGauge{},
+	}
+
+	for _, opt := range opts {
+		opt(storageMetrics)
+	}
 
 	if err := storeMetrics(meter, storageMetrics); err != nil {
 		return nil, err
@@ -178,43 +380,49 @@ func NewStorageMetrics(meter metric.Meter) (*StorageMetrics, error) {
 		return nil, err
 	}
 
+	if err := forecastMetrics(meter, storageMetrics); err != nil {
+		return nil, err
+	}
+
+	// The status of the targets is only reported if any storage metrics are collected from them.
+	if len(storageMetrics.instruments) > 0 {
+		if err := targetMetrics(meter, storageMetrics); err != nil {
+			return nil, err
+		}
+	}
+
+	storageMetrics.policyMetrics = storageMetrics.needsPolicies()
+
 	return storageMetrics, nil
 }
 
-// RegisterStorageMetrics registers storage metrics for the given Zadara client.
+// RegisterStorageMetrics registers storage metrics for the given Zadara client on the global meter provider.
 // It creates storage metrics using the provided meter and registers the metrics
 // callback to observe the storage metrics for the client.
 // Returns an error if there was a failure in creating or registering the metrics.
-func RegisterStorageMetrics(targets []*config.Target) error {
-	meter := otel.Meter("zadara")
+func RegisterStorageMetrics(targets []*config.Target, opts ...Option) error {
+	return RegisterStorageMetricsWithMeter(otel.Meter(meterName), targets, opts...)
+}
 
-	metrics, err := NewStorageMetrics(meter)
+// RegisterStorageMetricsWithMeter registers storage metrics for the given Zadara client on the meter.
+// It is used to collect the metrics with a reader other than the global meter provider's.
+func RegisterStorageMetricsWithMeter(meter metric.Meter, targets []*config.Target, opts ...Option) error {
+	metrics, err := NewStorageMetrics(meter, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create storage metrics: %w", err)
 	}
 
-	_, err = meter.RegisterCallback(metrics.StorageMetricsObserve(targets,
-		func(_ context.Context, target *config.Target) ZadaraClient {
-			return commandcenter.NewClient(target)
-		}),
-		metrics.FreeStorage,
-		metrics.UsedStorage,
-		metrics.AccountsCount,
-		metrics.UsersCount,
-		metrics.ContainersCount,
-		metrics.ObjectsCount,
-		metrics.DrivesCount,
-		metrics.Cache,
-		metrics.HealthPercentage,
-		metrics.RebalancePercentage,
-		metrics.PercentageDrivesAdded,
-		metrics.RingBalanceNormalPercentage,
-		metrics.RingBalanceDegradedPercentage,
-		metrics.RingBalanceCriticalPercentage,
-		metrics.RingBalanceNormalCount,
-		metrics.RingBalanceDegradedCount,
-		metrics.RingBalanceCriticalCount,
-	)
+	if err := metrics.labels.Validate(targets); err != nil {
+		return fmt.Errorf("invalid labels: %w", err)
+	}
+
+	if len(metrics.instruments) == 0 {
+		slog.Warn("all storage metrics are filtered out")
+
+		return nil
+	}
+
+	_, err = meter.RegisterCallback(metrics.StorageMetricsObserve(targets, metrics.newClient), metrics.instruments...)
 	if err != nil {
 		return fmt.Errorf("failed to register storage metrics: %w", err)
 	}
@@ -2,10 +2,18 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/forecast"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
 	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -14,109 +22,496 @@ import (
 type (
 	// ClientFunc is a function that returns a ZadaraClient.
 	ClientFunc func(ctx context.Context, target *config.Target) ZadaraClient
+
+	// TargetsFunc is a function that returns the current targets, such as the discovered targets.
+	TargetsFunc func() []*config.Target
+
+	// CloudDiscoverer is implemented by the clients that can discover the clouds of their Command Center.
+	CloudDiscoverer interface {
+		// CloudTargets returns the target of each discovered cloud, or the target itself if its clouds are not
+		// discovered.
+		CloudTargets(ctx context.Context, target *config.Target) ([]*config.Target, error)
+	}
+
+	// targetStores is the result of retrieving the stores of a target, or of one of its discovered clouds.
+	targetStores struct {
+		target *config.Target
+		stores []*commandcenter.StoreStoragePolicies
+		err    error
+	}
 )
 
+var (
+	// ErrTargetTimeout is returned when the stores of a target are not retrieved before the scrape deadline.
+	ErrTargetTimeout = errors.New("timed out collecting target")
+
+	// ErrDiscoveryUnsupported is returned when discovering the clouds of a target whose client cannot discover them.
+	ErrDiscoveryUnsupported = errors.New("client does not support cloud discovery")
+)
+
+// percent is the number of percent in a whole, to convert the percentages of the API to ratios.
+const percent = 100
+
+// observeInt64 observes the value of the metric, and of its legacy metric if enabled.
+// Nothing is observed if the metric is filtered out.
+func (sm *StorageMetrics) observeInt64(
+	o metric.Observer,
+	gauge metric.Int64ObservableGauge,
+	name string,
+	value int64,
+	attrs metric.MeasurementOption,
+) {
+	if gauge == nil {
+		return
+	}
+
+	o.ObserveInt64(gauge, value, attrs)
+
+	if legacy, ok := sm.legacyInt64[name]; ok {
+		o.ObserveInt64(legacy, value, attrs)
+	}
+}
+
+// observeRatio observes the percentage as a ratio, and as a percentage on its legacy metric if enabled.
+// Nothing is observed if the metric is filtered out.
+func (sm *StorageMetrics) observeRatio(
+	o metric.Observer,
+	gauge metric.Float64ObservableGauge,
+	name string,
+	percentage float64,
+	attrs metric.MeasurementOption,
+) {
+	if gauge == nil {
+		return
+	}
+
+	o.ObserveFloat64(gauge, percentage/percent, attrs)
+
+	if legacy, ok := sm.legacyFloat64[name]; ok {
+		o.ObserveFloat64(legacy, percentage, attrs)
+	}
+}
+
 func (sm *StorageMetrics) observePolicy(
 	o metric.Observer,
 	policy *vpsaobjectstorage.ZiosStoragePolicy,
 	attrs metric.MeasurementOption,
 ) error {
-	// Observe the percentage of drives added metric.
+	// Observe the ratio of drives added metric.
 	drivesAdded, err := strconv.ParseFloat(policy.PercentageDrivesAdded, 64)
 	if err != nil {
 		return fmt.Errorf("error parsing drives added: %w", err)
 	}
 
-	o.ObserveFloat64(sm.PercentageDrivesAdded, drivesAdded, attrs)
+	sm.observeRatio(o, sm.DrivesAddedRatio, DrivesAddedRatioName, drivesAdded, attrs)
 
-	o.ObserveFloat64(sm.RingBalanceNormalPercentage, policy.RingBalance.NormalPercentage, attrs)
-	o.ObserveFloat64(sm.RingBalanceDegradedPercentage, policy.RingBalance.DegradedPercentage, attrs)
-	o.ObserveFloat64(sm.RingBalanceCriticalPercentage, policy.RingBalance.CriticalPercentage, attrs)
-	o.ObserveInt64(sm.FreeStorage, policy.FreeCapacity, attrs)
-	o.ObserveInt64(sm.UsedStorage, policy.UsedCapacity, attrs)
-	o.ObserveFloat64(sm.HealthPercentage, policy.HealthPercentage, attrs)
-	o.ObserveFloat64(sm.RebalancePercentage, policy.RebalancePercentage, attrs)
-	o.ObserveInt64(sm.RingBalanceNormalCount, policy.RingBalance.NormalCount, attrs)
-	o.ObserveInt64(sm.RingBalanceDegradedCount, policy.RingBalance.DegradedCount, attrs)
-	o.ObserveInt64(sm.RingBalanceCriticalCount, policy.RingBalance.CriticalCount, attrs)
+	ringBalance := policy.RingBalance
+	sm.observeRatio(o, sm.RingBalanceNormalRatio, RingBalanceNormalRatioName, ringBalance.NormalPercentage, attrs)
+	sm.observeRatio(o, sm.RingBalanceDegradedRatio, RingBalanceDegradedRatioName, ringBalance.DegradedPercentage, attrs)
+	sm.observeRatio(o, sm.RingBalanceCriticalRatio, RingBalanceCriticalRatioName, ringBalance.CriticalPercentage, attrs)
+	sm.observeInt64(o, sm.FreeStorage, FreeStorageName, policy.FreeCapacity, attrs)
+	sm.observeInt64(o, sm.UsedStorage, UsedStorageName, policy.UsedCapacity, attrs)
+	sm.observeRatio(o, sm.HealthRatio, HealthRatioName, policy.HealthPercentage, attrs)
+	sm.observeRatio(o, sm.RebalanceRatio, RebalanceRatioName, policy.RebalancePercentage, attrs)
+	sm.observeInt64(o, sm.RingBalanceNormalCount, RingBalanceNormalName, ringBalance.NormalCount, attrs)
+	sm.observeInt64(o, sm.RingBalanceDegradedCount, RingBalanceDegradedName, ringBalance.DegradedCount, attrs)
+	sm.observeInt64(o, sm.RingBalanceCriticalCount, RingBalanceCriticalName, ringBalance.CriticalCount, attrs)
 
 	return nil
 }
 
-func (sm *StorageMetrics) observeStores(
-	ctx context.Context,
+// observeForecast records the used capacity of the policy and observes the resulting forecast.
+// It does nothing if forecasting is not enabled or the forecast metrics are filtered out.
+func (sm *StorageMetrics) observeForecast(
 	o metric.Observer,
+	key string,
+	policy *vpsaobjectstorage.ZiosStoragePolicy,
+	attrs metric.MeasurementOption,
+) {
+	if sm.forecaster == nil || (sm.GrowthBytesPerDay == nil && sm.PredictedFullTimestamp == nil) {
+		return
+	}
+
+	sm.forecaster.Add(key, forecast.Sample{Timestamp: time.Now(), Used: policy.UsedCapacity})
+
+	prediction, ok := sm.forecaster.Predict(key, policy.FreeCapacity)
+	if !ok {
+		return
+	}
+
+	if sm.GrowthBytesPerDay != nil {
+		o.ObserveFloat64(sm.GrowthBytesPerDay, prediction.GrowthBytesPerDay, attrs)
+	}
+
+	if sm.PredictedFullTimestamp != nil && !prediction.FullAt.IsZero() {
+		o.ObserveFloat64(sm.PredictedFullTimestamp, float64(prediction.FullAt.Unix()), attrs)
+	}
+}
+
+// saveForecast discards the series of removed policies and persists the forecast samples,
+// if forecasting is enabled.
+func (sm *StorageMetrics) saveForecast() {
+	if sm.forecaster == nil {
+		return
+	}
+
+	sm.forecaster.Prune(time.Now())
+
+	if err := sm.forecaster.Save(); err != nil {
+		slog.Error("error saving forecast state", "error", err)
+	}
+}
+
+// getStores retrieves the stores of the target, along with their storage policies if any policy metrics are
+// collected. Otherwise the storage policies are not requested from the API.
+func (sm *StorageMetrics) getStores(
+	ctx context.Context,
 	target *config.Target,
 	client ZadaraClient,
-) error {
-	// Retrieve the storage policies from the ZadaraClient.
-	stores, err := client.GetAllStoragePolicies(ctx)
+) ([]*commandcenter.StoreStoragePolicies, error) {
+	if sm.policyMetrics {
+		stores, err := client.GetAllStoragePolicies(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting storage policies: %w", err)
+		}
+
+		return stores, nil
+	}
+
+	res, err := client.GetStores(ctx, target.CloudName)
 	if err != nil {
-		return fmt.Errorf("error getting storage policies: %w", err)
+		return nil, fmt.Errorf("error getting stores: %w", err)
+	}
+
+	stores := make([]*commandcenter.StoreStoragePolicies, len(res.Zioses))
+	for i, store := range res.Zioses {
+		stores[i] = &commandcenter.StoreStoragePolicies{Store: store}
 	}
 
+	return stores, nil
+}
+
+func (sm *StorageMetrics) observeStores(
+	o metric.Observer,
+	target *config.Target,
+	stores []*commandcenter.StoreStoragePolicies,
+) error {
 	// Define the cloud name attribute.
-	cloudNameAttr := attribute.String("cloud_name", target.CloudName)
-	targeNameAttr := attribute.String("name", target.Name)
+	cloudNameAttr := attribute.String(CloudNameLabel, target.CloudName)
+	targeNameAttr := attribute.String(NameLabel, target.Name)
 
 	for _, ssc := range stores {
 		store := ssc.Store
 		policies := ssc.Policies
-		storeAttr := attribute.String("store", store.Name+"@"+target.CloudName)
-		storeNameAttr := attribute.String("store_name", store.Name)
+		storeAttr := attribute.String(StoreLabel, store.Name+"@"+target.CloudName)
+		storeNameAttr := attribute.String(StoreNameLabel, store.Name)
 
-		storeLevelAttrs := metric.WithAttributes(
+		storeLevelAttrs := metric.WithAttributes(sm.labels.attributes(target,
 			targeNameAttr,
 			cloudNameAttr,
 			storeNameAttr,
 			storeAttr,
-		)
+		)...)
 
-		o.ObserveInt64(sm.AccountsCount, store.AccountsCount, storeLevelAttrs)
-		o.ObserveInt64(sm.UsersCount, store.UsersCount, storeLevelAttrs)
-		o.ObserveInt64(sm.ContainersCount, store.ContainersCount, storeLevelAttrs)
-		o.ObserveInt64(sm.ObjectsCount, store.ObjectsCount, storeLevelAttrs)
-		o.ObserveInt64(sm.DrivesCount, store.Drives, storeLevelAttrs)
-		o.ObserveInt64(sm.Cache, store.Cache, storeLevelAttrs)
+		sm.observeInt64(o, sm.AccountsCount, AccountsName, store.AccountsCount, storeLevelAttrs)
+		sm.observeInt64(o, sm.UsersCount, UsersName, store.UsersCount, storeLevelAttrs)
+		sm.observeInt64(o, sm.ContainersCount, ContainersName, store.ContainersCount, storeLevelAttrs)
+		sm.observeInt64(o, sm.ObjectsCount, ObjectsName, store.ObjectsCount, storeLevelAttrs)
+		sm.observeInt64(o, sm.DrivesCount, DrivesName, store.Drives, storeLevelAttrs)
+		sm.observeInt64(o, sm.Cache, CacheName, store.Cache, storeLevelAttrs)
 
 		// Iterate over each policy.
 		for _, policy := range policies {
 			// Define the policy level attributes.
-			policyLevelAttrs := metric.WithAttributes(
+			policyLevelAttrs := metric.WithAttributes(sm.labels.attributes(target,
 				targeNameAttr,
 				cloudNameAttr,
 				storeNameAttr,
 				storeAttr,
-				attribute.String("policy_name", policy.Name),
-			)
+				attribute.String(PolicyNameLabel, policy.Name),
+			)...)
 
 			if err := sm.observePolicy(o, policy, policyLevelAttrs); err != nil {
 				return err
 			}
+
+			forecastKey := strings.Join([]string{target.Name, target.CloudName, store.Name, policy.Name}, "/")
+			sm.observeForecast(o, forecastKey, policy, policyLevelAttrs)
 		}
 	}
 
 	return nil
 }
 
-// StorageMetricsObserve returns a metric callback function that observes storage metrics for the given targets.
+// collectTarget retrieves the stores of the target, or of each of its clouds concurrently if they are discovered,
+// recording the results of the clouds in clouds as they are retrieved.
+func (sm *StorageMetrics) collectTarget(
+	ctx context.Context,
+	target *config.Target,
+	newclient ClientFunc,
+	clouds *cloudStores,
+) []*targetStores {
+	client := newclient(ctx, target)
+
+	if !target.DiscoverClouds.Enabled {
+		stores, err := sm.getStores(ctx, target, client)
+
+		return []*targetStores{{target: target, stores: stores, err: err}}
+	}
+
+	discoverer, ok := client.(CloudDiscoverer)
+	if !ok {
+		return []*targetStores{{target: target, err: ErrDiscoveryUnsupported}}
+	}
+
+	cloudTargets, err := discoverer.CloudTargets(ctx, target)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return sm.timedOut(target)
+	}
+
+	if err != nil {
+		return []*targetStores{{target: target, err: fmt.Errorf("error discovering clouds: %w", err)}}
+	}
+
+	sm.setClouds(target, cloudTargets)
+	clouds.start(cloudTargets)
+
+	var wg sync.WaitGroup
+
+	for i, cloudTarget := range cloudTargets {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			stores, err := sm.getStores(ctx, cloudTarget, newclient(ctx, cloudTarget))
+			clouds.set(i, &targetStores{target: cloudTarget, stores: stores, err: err})
+		}()
+	}
+
+	wg.Wait()
+
+	return clouds.timedOut()
+}
+
+// cloudStores records the results of the discovered clouds of a target as they are retrieved, so the clouds
+// retrieved in time are observed even if the others time out.
+type cloudStores struct {
+	mu      sync.Mutex
+	results []*targetStores
+}
+
+// start records the discovered clouds, none of which has been retrieved yet.
+func (c *cloudStores) start(cloudTargets []*config.Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results = make([]*targetStores, len(cloudTargets))
+	for i, cloudTarget := range cloudTargets {
+		c.results[i] = &targetStores{target: cloudTarget, err: context.DeadlineExceeded}
+	}
+}
+
+// set records the result of the i-th cloud.
+func (c *cloudStores) set(i int, result *targetStores) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results[i] = result
+}
+
+// timedOut returns the results of the clouds, those not retrieved yet having timed out,
+// or nil if the clouds have not been discovered yet.
+func (c *cloudStores) timedOut() []*targetStores {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.results)
+}
+
+// setClouds records the clouds discovered for the target.
+func (sm *StorageMetrics) setClouds(target *config.Target, cloudTargets []*config.Target) {
+	sm.cloudsMu.Lock()
+	defer sm.cloudsMu.Unlock()
+
+	if sm.clouds == nil {
+		sm.clouds = map[string][]*config.Target{}
+	}
+
+	sm.clouds[target.Name] = cloudTargets
+}
+
+// timedOut returns the timed out results of the target, which are those of the clouds last discovered for it
+// if its clouds are discovered and have been, or of the target itself otherwise.
+func (sm *StorageMetrics) timedOut(target *config.Target) []*targetStores {
+	var cloudTargets []*config.Target
+
+	if target.DiscoverClouds.Enabled {
+		sm.cloudsMu.Lock()
+		cloudTargets = sm.clouds[target.Name]
+		sm.cloudsMu.Unlock()
+	}
+
+	if len(cloudTargets) == 0 {
+		return []*targetStores{{target: target, err: context.DeadlineExceeded}}
+	}
+
+	results := make([]*targetStores, len(cloudTargets))
+	for i, cloudTarget := range cloudTargets {
+		results[i] = &targetStores{target: cloudTarget, err: context.DeadlineExceeded}
+	}
+
+	return results
+}
+
+// collectStores retrieves the stores of the targets concurrently, until they are all retrieved or the context
+// is done. The results of the targets that were not retrieved in time are those of their discovered clouds,
+// if discovered, and nil otherwise.
+func (sm *StorageMetrics) collectStores(
+	ctx context.Context,
+	targets []*config.Target,
+	newclient ClientFunc,
+) [][]*targetStores {
+	type indexedStores struct {
+		index  int
+		stores []*targetStores
+	}
+
+	ch := make(chan indexedStores, len(targets))
+	clouds := make([]cloudStores, len(targets))
+
+	for i, target := range targets {
+		go func() {
+			ch <- indexedStores{index: i, stores: sm.collectTarget(ctx, target, newclient, &clouds[i])}
+		}()
+	}
+
+	results := make([][]*targetStores, len(targets))
+
+	for range targets {
+		select {
+		case result := <-ch:
+			results[result.index] = result.stores
+		case <-ctx.Done():
+			for i := range results {
+				if results[i] == nil {
+					results[i] = clouds[i].timedOut()
+				}
+			}
+
+			return results
+		}
+	}
+
+	return results
+}
+
+// observeTarget observes the status of the target from the error collecting it, if any.
+// Authentication failures are told apart from other errors, so revoked or expired tokens can be alerted on.
+func (sm *StorageMetrics) observeTarget(o metric.Observer, target *config.Target, err error) {
+	attrs := metric.WithAttributes(sm.labels.attributes(target,
+		attribute.String(NameLabel, target.Name),
+		attribute.String(CloudNameLabel, target.CloudName),
+	)...)
+
+	timedOut := errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTargetTimeout)
+
+	o.ObserveInt64(sm.TargetUp, boolValue(err == nil), attrs)
+	o.ObserveInt64(sm.TargetTimedOut, boolValue(timedOut), attrs)
+	o.ObserveInt64(sm.TargetAuthFailed, boolValue(vpsaobjectstorage.IsUnauthorized(err)), attrs)
+}
+
+// observeResult observes the storage metrics and status of the target whose stores were retrieved.
+func (sm *StorageMetrics) observeResult(o metric.Observer, result *targetStores) error {
+	target := result.target
+
+	if errors.Is(result.err, context.DeadlineExceeded) {
+		sm.observeTarget(o, target, result.err)
+
+		return fmt.Errorf("%w: %s", ErrTargetTimeout, target.Name)
+	}
+
+	err := result.err
+	if err == nil {
+		err = sm.observeStores(o, target, result.stores)
+	}
+
+	sm.observeTarget(o, target, err)
+
+	if err != nil {
+		return fmt.Errorf("error collecting %s: %w", target.Name, err)
+	}
+
+	return nil
+}
+
+// currentTargets returns the targets of the targets function, if set, without those whose labels are invalid,
+// or the targets otherwise.
+func (sm *StorageMetrics) currentTargets(targets []*config.Target) ([]*config.Target, []error) {
+	if sm.targets == nil {
+		return targets, nil
+	}
+
+	var (
+		valid []*config.Target
+		errs  []error
+	)
+
+	for _, target := range sm.targets() {
+		if err := sm.labels.Validate([]*config.Target{target}); err != nil {
+			errs = append(errs, fmt.Errorf("invalid labels: %w", err))
+
+			continue
+		}
+
+		valid = append(valid, target)
+	}
+
+	return valid, errs
+}
+
+// boolValue returns 1 if b is true and 0 otherwise.
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// StorageMetricsObserve returns a metric callback function that observes storage metrics for the given targets,
+// or for the targets of the targets function on each collection if set, skipping those with invalid labels.
 // It takes a slice of targets and a newclient function as parameters.
 // The newclient function is used to create a new client for each target.
-// The metric callback function iterates over the targets,
-// creates a client for each target using the newclient function,
-// and calls the observeStores function to observe the storage metrics for the target using the client.
-// If any error occurs during the observation, it is returned.
-// If all observations are successful, nil is returned.
+// The metric callback function retrieves the stores of the targets concurrently, or of each of their clouds
+// if they are discovered, creating a client for each target and cloud using the newclient function,
+// and observes the storage metrics of the targets whose stores were retrieved before the scrape deadline,
+// if any. The other targets are marked as down, and as timed out if they were not retrieved in time,
+// each of the clouds last discovered for them if their clouds are discovered.
+// The errors of all the targets are returned.
 func (sm *StorageMetrics) StorageMetricsObserve(targets []*config.Target, newclient ClientFunc) metric.Callback {
 	// Define the metric callback function.
 	return func(ctx context.Context, o metric.Observer) error {
-		for _, target := range targets {
-			client := newclient(ctx, target)
-			if err := sm.observeStores(ctx, o, target, client); err != nil {
-				return err
+		defer sm.saveForecast()
+
+		ctx, cancel := sm.deadlines.Context(ctx)
+		defer cancel()
+
+		current, errs := sm.currentTargets(targets)
+		results := sm.collectStores(ctx, current, newclient)
+
+		for i, target := range current {
+			if results[i] == nil {
+				results[i] = sm.timedOut(target)
+			}
+
+			for _, result := range results[i] {
+				if err := sm.observeResult(o, result); err != nil {
+					errs = append(errs, err)
+				}
 			}
 		}
 
-		return nil
+		return errors.Join(errs...)
 	}
 }
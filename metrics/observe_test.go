@@ -2,18 +2,26 @@ package metrics_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/krystal/zadara-exporter/config"
 	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/simulator"
 	"github.com/krystal/zadara-exporter/zadara/commandcenter"
 	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/embedded"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 type (
@@ -39,6 +47,17 @@ func (m *mockZadaraClient) GetAllStoragePolicies(
 	return firstArg, nil
 }
 
+func (m *mockZadaraClient) GetStores(ctx context.Context, cloudName string) (*vpsaobjectstorage.ZiosResponse, error) {
+	args := m.Called(ctx, cloudName)
+
+	firstArg, ok := args.Get(0).(*vpsaobjectstorage.ZiosResponse)
+	if !ok {
+		return nil, fmt.Errorf("error with arg: %w", args.Error(1))
+	}
+
+	return firstArg, nil
+}
+
 func (m *mockObserver) ObserveInt64(obsrv metric.Int64Observable, value int64, opts ...metric.ObserveOption) {
 	m.Called(obsrv, value, opts)
 }
@@ -47,6 +66,11 @@ func (m *mockObserver) ObserveFloat64(obsrv metric.Float64Observable, value floa
 	m.Called(obsrv, value, opts)
 }
 
+// ratio converts the percentage to the ratio observed by the storage metrics.
+func ratio(percentage float64) float64 {
+	return percentage / 100
+}
+
 //nolint:funlen // majority of the length is mocking
 func TestStorageMetricsObserve(t *testing.T) {
 	t.Parallel()
@@ -140,19 +164,19 @@ func TestStorageMetricsObserve(t *testing.T) {
 		// Policy 1 Metrics.
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.PercentageDrivesAdded, 55.2, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.DrivesAddedRatio, ratio(55.2), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RingBalanceNormalPercentage, 75.0, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RingBalanceNormalRatio, ratio(75.0), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RingBalanceDegradedPercentage, 12.5, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RingBalanceDegradedRatio, ratio(12.5), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RingBalanceCriticalPercentage, 12.5, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RingBalanceCriticalRatio, ratio(12.5), mock.Anything},
 		},
 		{
 			Method:    "ObserveInt64",
@@ -164,11 +188,11 @@ func TestStorageMetricsObserve(t *testing.T) {
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.HealthPercentage, 99.9, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.HealthRatio, ratio(99.9), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RebalancePercentage, 50.0, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RebalanceRatio, ratio(50.0), mock.Anything},
 		},
 		{
 			Method:    "ObserveInt64",
@@ -185,19 +209,19 @@ func TestStorageMetricsObserve(t *testing.T) {
 		// Policy 2 Metrics.
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.PercentageDrivesAdded, 74.3, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.DrivesAddedRatio, ratio(74.3), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RingBalanceNormalPercentage, 100.0, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RingBalanceNormalRatio, ratio(100.0), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RingBalanceDegradedPercentage, 0.0, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RingBalanceDegradedRatio, ratio(0.0), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RingBalanceCriticalPercentage, 0.0, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RingBalanceCriticalRatio, ratio(0.0), mock.Anything},
 		},
 		{
 			Method:    "ObserveInt64",
@@ -209,11 +233,11 @@ func TestStorageMetricsObserve(t *testing.T) {
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.HealthPercentage, 89.9, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.HealthRatio, ratio(89.9), mock.Anything},
 		},
 		{
 			Method:    "ObserveFloat64",
-			Arguments: mock.Arguments{storageMetrics.RebalancePercentage, 90.0, mock.Anything},
+			Arguments: mock.Arguments{storageMetrics.RebalanceRatio, ratio(90.0), mock.Anything},
 		},
 		{
 			Method:    "ObserveInt64",
@@ -227,6 +251,18 @@ func TestStorageMetricsObserve(t *testing.T) {
 			Method:    "ObserveInt64",
 			Arguments: mock.Arguments{storageMetrics.RingBalanceCriticalCount, int64(0), mock.Anything},
 		},
+		{
+			Method:    "ObserveInt64",
+			Arguments: mock.Arguments{storageMetrics.TargetUp, int64(1), mock.Anything},
+		},
+		{
+			Method:    "ObserveInt64",
+			Arguments: mock.Arguments{storageMetrics.TargetTimedOut, int64(0), mock.Anything},
+		},
+		{
+			Method:    "ObserveInt64",
+			Arguments: mock.Arguments{storageMetrics.TargetAuthFailed, int64(0), mock.Anything},
+		},
 	}
 
 	// Call the function being tested.
@@ -244,3 +280,229 @@ func TestStorageMetricsObserve(t *testing.T) {
 	// Assert that the mock client's method was called with the expected arguments.
 	mockClient.AssertCalled(t, "GetAllStoragePolicies", mock.Anything)
 }
+
+func TestStorageMetricsLegacyNames(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(mockZadaraClient)
+	mockClient.On("GetAllStoragePolicies", mock.Anything).Return([]*commandcenter.StoreStoragePolicies{
+		{
+			Store: &vpsaobjectstorage.Zios{Name: "store1", ObjectsCount: 78, Cache: 1670},
+			Policies: []*vpsaobjectstorage.ZiosStoragePolicy{
+				{
+					Name:                  "policy1",
+					FreeCapacity:          100,
+					HealthPercentage:      99.5,
+					PercentageDrivesAdded: "50",
+					RingBalance:           vpsaobjectstorage.RingBalance{DegradedPercentage: 12.5, DegradedCount: 25},
+				},
+			},
+		},
+	}, nil)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	err := metrics.RegisterStorageMetricsWithMeter(meter, []*config.Target{{Name: "target", CloudName: "cc1"}},
+		metrics.WithClientFunc(func(_ context.Context, _ *config.Target) metrics.ZadaraClient {
+			return mockClient
+		}),
+		metrics.WithLegacyNames(),
+	)
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	values := map[string]float64{}
+	descriptions := map[string]string{}
+
+	for _, sample := range metrics.Samples("zadara", &rm) {
+		values[sample.Name] = sample.Value
+		descriptions[sample.Name] = sample.Description
+	}
+
+	assert.InDelta(t, 0.995, values["zadara_health_ratio"], 1e-9)
+	assert.InDelta(t, 99.5, values["zadara_health_percentage"], 1e-9)
+	assert.InDelta(t, 0.125, values["zadara_ring_balance_degraded_ratio"], 1e-9)
+	assert.InDelta(t, 12.5, values["zadara_ring_balance_Degraded_percentage"], 1e-9)
+	assert.InDelta(t, 25, values["zadara_ring_balance_degraded"], 1e-9)
+	assert.InDelta(t, 25, values["zadara_ring_balance_Degraded_count"], 1e-9)
+	assert.InDelta(t, 100, values["zadara_free_storage_bytes"], 1e-9)
+	assert.InDelta(t, 100, values["zadara_free_storage"], 1e-9)
+	assert.InDelta(t, 78, values["zadara_objects"], 1e-9)
+	assert.InDelta(t, 78, values["zadara_objects_count"], 1e-9)
+	assert.InDelta(t, 1670, values["zadara_cache"], 1e-9)
+
+	// The legacy metrics keep their original help text, so nothing changes for their consumers.
+	assert.Equal(t, "The number of objects in the Zadara store.", descriptions["zadara_objects_count"])
+	assert.Equal(t, "The percentage of health in the Zadara store.", descriptions["zadara_health_percentage"])
+}
+
+func TestStorageMetricsDiscoverClouds(t *testing.T) {
+	t.Parallel()
+
+	fixture, err := simulator.DecodeFixture(strings.NewReader(`
+clouds:
+  cc1:
+    stores:
+      - name: store1
+        objects_count: 10
+  cc2:
+    stores:
+      - name: store2
+        objects_count: 20
+  lab:
+    stores:
+      - name: store3
+        objects_count: 30
+`))
+	require.NoError(t, err)
+
+	server := httptest.NewServer(simulator.New(fixture))
+	defer server.Close()
+
+	filter, err := metrics.NewFilter(metrics.FilterConfig{Groups: map[string]bool{"policy": false, "ring_balance": false}})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	pool := commandcenter.NewPool()
+
+	err = metrics.RegisterStorageMetricsWithMeter(meter, []*config.Target{{
+		Name:           "London",
+		URL:            server.URL,
+		DiscoverClouds: config.CloudDiscovery{Enabled: true, Exclude: []string{"lab"}},
+	}},
+		metrics.WithClientFunc(func(_ context.Context, target *config.Target) metrics.ZadaraClient {
+			return pool.Client(target)
+		}),
+		metrics.WithFilter(filter),
+	)
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	values := map[string]float64{}
+
+	for _, sample := range metrics.Samples("zadara", &rm) {
+		if sample.Name == "zadara_objects" || sample.Name == "zadara_target_up" {
+			values[sample.Name+" "+sample.Labels["cloud_name"]] = sample.Value
+		}
+	}
+
+	assert.Equal(t, map[string]float64{
+		"zadara_objects cc1":   10,
+		"zadara_objects cc2":   20,
+		"zadara_target_up cc1": 1,
+		"zadara_target_up cc2": 1,
+	}, values)
+}
+
+func TestStorageMetricsTargetsFunc(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(mockZadaraClient)
+	mockClient.On("GetStores", mock.Anything, mock.Anything).Return(&vpsaobjectstorage.ZiosResponse{
+		Zioses: []*vpsaobjectstorage.Zios{{Name: "store1", ObjectsCount: 78}},
+	}, nil)
+
+	filter, err := metrics.NewFilter(metrics.FilterConfig{Groups: map[string]bool{"policy": false, "ring_balance": false}})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	targets := []*config.Target{{Name: "London", CloudName: "cc1"}}
+
+	err = metrics.RegisterStorageMetricsWithMeter(meter, nil,
+		metrics.WithClientFunc(func(_ context.Context, _ *config.Target) metrics.ZadaraClient {
+			return mockClient
+		}),
+		metrics.WithFilter(filter),
+		metrics.WithTargetsFunc(func() []*config.Target { return targets }),
+	)
+	require.NoError(t, err)
+
+	collect := func() ([]string, error) {
+		var rm metricdata.ResourceMetrics
+		err := reader.Collect(context.Background(), &rm)
+
+		var names []string
+
+		for _, sample := range metrics.Samples("zadara", &rm) {
+			if sample.Name == "zadara_objects" {
+				names = append(names, sample.Labels["name"])
+			}
+		}
+
+		return names, err
+	}
+
+	names, err := collect()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"London"}, names)
+
+	// The targets are read on each collection, skipping those with invalid labels.
+	targets = []*config.Target{
+		{Name: "New York", CloudName: "cc2"},
+		{Name: "Paris", CloudName: "cc3", Labels: map[string]string{"cloud_name": "duplicate"}},
+	}
+
+	names, err = collect()
+	// The SDK does not wrap the errors of the callbacks.
+	require.ErrorContains(t, err, metrics.ErrDuplicateLabel.Error())
+	assert.Equal(t, []string{"New York"}, names)
+}
+
+func TestStorageMetricsAuthFailed(t *testing.T) {
+	t.Parallel()
+
+	rejected := new(mockZadaraClient)
+	rejected.On("GetStores", mock.Anything, "cc1").
+		Return(nil, &vpsaobjectstorage.APIError{StatusCode: http.StatusUnauthorized, Message: "Invalid token"})
+
+	unreachable := new(mockZadaraClient)
+	unreachable.On("GetStores", mock.Anything, "cc2").Return(nil, errors.New("connection refused"))
+
+	filter, err := metrics.NewFilter(metrics.FilterConfig{Groups: map[string]bool{"policy": false, "ring_balance": false}})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	err = metrics.RegisterStorageMetricsWithMeter(meter, []*config.Target{
+		{Name: "London", CloudName: "cc1"},
+		{Name: "New York", CloudName: "cc2"},
+	},
+		metrics.WithClientFunc(func(_ context.Context, target *config.Target) metrics.ZadaraClient {
+			if target.Name == "London" {
+				return rejected
+			}
+
+			return unreachable
+		}),
+		metrics.WithFilter(filter),
+	)
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.Error(t, reader.Collect(context.Background(), &rm))
+
+	values := map[string]float64{}
+
+	for _, sample := range metrics.Samples("zadara", &rm) {
+		values[sample.Name+" "+sample.Labels["name"]] = sample.Value
+	}
+
+	// Authentication failures are told apart from connectivity errors.
+	assert.Equal(t, map[string]float64{
+		"zadara_target_up London":            0,
+		"zadara_target_timed_out London":     0,
+		"zadara_target_auth_failed London":   1,
+		"zadara_target_up New York":          0,
+		"zadara_target_timed_out New York":   0,
+		"zadara_target_auth_failed New York": 0,
+	}, values)
+}
@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/credentials"
+)
+
+type (
+	// OTLPConfig represents the configuration of the OTLP exporter.
+	OTLPConfig struct {
+		Enabled     bool              `mapstructure:"enabled"`
+		Protocol    string            `mapstructure:"protocol"`
+		Endpoint    string            `mapstructure:"endpoint"`
+		Insecure    bool              `mapstructure:"insecure"`
+		Headers     map[string]string `mapstructure:"headers"`
+		TLS         OTLPTLSConfig     `mapstructure:"tls"`
+		Interval    time.Duration     `mapstructure:"interval"`
+		Timeout     time.Duration     `mapstructure:"timeout"`
+		Temporality string            `mapstructure:"temporality"`
+	}
+
+	// OTLPTLSConfig represents the TLS configuration of the OTLP exporter.
+	OTLPTLSConfig struct {
+		CAFile             string `mapstructure:"ca_file"`
+		CertFile           string `mapstructure:"cert_file"`
+		KeyFile            string `mapstructure:"key_file"`
+		ServerName         string `mapstructure:"server_name"`
+		InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	}
+)
+
+const (
+	// OTLPProtocolGRPC is the OTLP over gRPC protocol.
+	OTLPProtocolGRPC = "grpc"
+
+	// OTLPProtocolHTTP is the OTLP over HTTP with protobuf payloads protocol.
+	OTLPProtocolHTTP = "http/protobuf"
+
+	// TemporalityCumulative reports sums cumulatively since the exporter started.
+	TemporalityCumulative = "cumulative"
+
+	// TemporalityDelta reports sums as the change since the last export.
+	TemporalityDelta = "delta"
+
+	// DefaultOTLPInterval is the default interval between OTLP exports.
+	DefaultOTLPInterval = time.Minute
+)
+
+var (
+	// ErrUnknownOTLPProtocol is returned when the OTLP protocol is not supported.
+	ErrUnknownOTLPProtocol = errors.New("unknown OTLP protocol")
+
+	// ErrUnknownTemporality is returned when the temporality is not supported.
+	ErrUnknownTemporality = errors.New("unknown temporality")
+
+	// ErrInvalidCA is returned when the CA file does not contain any certificates.
+	ErrInvalidCA = errors.New("no certificates found in CA file")
+)
+
+// deltaTemporality returns delta temporality for the instruments that support it,
+// and cumulative temporality for up down counters, as recommended by the OTLP specification.
+func deltaTemporality(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindUpDownCounter, metric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+// temporalitySelector returns the temporality selector for the configured temporality.
+func temporalitySelector(temporality string) (metric.TemporalitySelector, error) {
+	switch strings.ToLower(temporality) {
+	case "", TemporalityCumulative:
+		return metric.DefaultTemporalitySelector, nil
+	case TemporalityDelta:
+		return deltaTemporality, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemporality, temporality)
+	}
+}
+
+// tlsConfig builds the TLS configuration for the OTLP exporter.
+func (c OTLPTLSConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicitly configured by the user
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCA, c.CAFile)
+		}
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// isURL reports whether the endpoint is a URL rather than a host and port.
+func isURL(endpoint string) bool {
+	return strings.Contains(endpoint, "://")
+}
+
+func newOTLPGRPCExporter(
+	ctx context.Context,
+	config OTLPConfig,
+	selector metric.TemporalitySelector,
+) (metric.Exporter, error) {
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithTemporalitySelector(selector)}
+
+	if config.Endpoint != "" {
+		if isURL(config.Endpoint) {
+			opts = append(opts, otlpmetricgrpc.WithEndpointURL(config.Endpoint))
+		} else {
+			opts = append(opts, otlpmetricgrpc.WithEndpoint(config.Endpoint))
+		}
+	}
+
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(config.Headers))
+	}
+
+	if config.Timeout > 0 {
+		opts = append(opts, otlpmetricgrpc.WithTimeout(config.Timeout))
+	}
+
+	if config.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else {
+		tlsConfig, err := config.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP gRPC exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+func newOTLPHTTPExporter(
+	ctx context.Context,
+	config OTLPConfig,
+	selector metric.TemporalitySelector,
+) (metric.Exporter, error) {
+	opts := []otlpmetrichttp.Option{otlpmetrichttp.WithTemporalitySelector(selector)}
+
+	if config.Endpoint != "" {
+		if isURL(config.Endpoint) {
+			opts = append(opts, otlpmetrichttp.WithEndpointURL(config.Endpoint))
+		} else {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(config.Endpoint))
+		}
+	}
+
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(config.Headers))
+	}
+
+	if config.Timeout > 0 {
+		opts = append(opts, otlpmetrichttp.WithTimeout(config.Timeout))
+	}
+
+	if config.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else {
+		tlsConfig, err := config.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+	}
+
+	exporter, err := otlpmetrichttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP HTTP exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+// NewOTLPReader creates a periodic reader that pushes the metrics to an OTLP endpoint.
+// The protocol is either grpc or http/protobuf, defaulting to grpc.
+func NewOTLPReader(ctx context.Context, config OTLPConfig) (metric.Reader, error) {
+	selector, err := temporalitySelector(config.Temporality)
+	if err != nil {
+		return nil, err
+	}
+
+	var exporter metric.Exporter
+
+	switch strings.ToLower(config.Protocol) {
+	case "", OTLPProtocolGRPC:
+		exporter, err = newOTLPGRPCExporter(ctx, config, selector)
+	case OTLPProtocolHTTP, "http":
+		exporter, err = newOTLPHTTPExporter(ctx, config, selector)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOTLPProtocol, config.Protocol)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	interval := config.Interval
+	if interval <= 0 {
+		interval = DefaultOTLPInterval
+	}
+
+	readerOpts := []metric.PeriodicReaderOption{metric.WithInterval(interval)}
+	if config.Timeout > 0 {
+		readerOpts = append(readerOpts, metric.WithTimeout(config.Timeout))
+	}
+
+	return metric.NewPeriodicReader(exporter, readerOpts...), nil
+}
@@ -0,0 +1,92 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/remotewrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestNewOTLPReader_HTTP(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	reader, err := metrics.NewOTLPReader(ctx, metrics.OTLPConfig{
+		Protocol:    metrics.OTLPProtocolHTTP,
+		Endpoint:    server.URL,
+		Headers:     map[string]string{"X-Api-Key": "secret"},
+		Interval:    time.Hour,
+		Temporality: metrics.TemporalityDelta,
+	})
+	require.NoError(t, err)
+
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	_, err = provider.Meter("test").Int64ObservableGauge("gauge",
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(1)
+
+			return nil
+		}))
+	require.NoError(t, err)
+
+	require.NoError(t, provider.ForceFlush(ctx))
+	require.NoError(t, provider.Shutdown(ctx))
+
+	assert.GreaterOrEqual(t, requests.Load(), int32(1))
+}
+
+func TestNewOTLPReader_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	_, err := metrics.NewOTLPReader(ctx, metrics.OTLPConfig{Protocol: "carrier-pigeon"})
+	require.ErrorIs(t, err, metrics.ErrUnknownOTLPProtocol)
+
+	_, err = metrics.NewOTLPReader(ctx, metrics.OTLPConfig{Temporality: "sometimes"})
+	require.ErrorIs(t, err, metrics.ErrUnknownTemporality)
+}
+
+func TestSetupExporters_NoExporters(t *testing.T) {
+	t.Parallel()
+
+	_, err := metrics.SetupExporters(context.Background(), metrics.ExporterConfig{})
+	require.ErrorIs(t, err, metrics.ErrNoExporters)
+}
+
+func TestSetupExporters_RemoteWriteError(t *testing.T) {
+	t.Parallel()
+
+	_, err := metrics.SetupExporters(context.Background(), metrics.ExporterConfig{
+		OTLP: metrics.OTLPConfig{
+			Enabled:  true,
+			Protocol: metrics.OTLPProtocolHTTP,
+			Endpoint: "http://localhost:4318",
+		},
+		RemoteWrite: remotewrite.Config{Enabled: true},
+	})
+	require.ErrorIs(t, err, remotewrite.ErrNoURL)
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Sample represents a single observation of a metric, named as it is exposed to Prometheus.
+type Sample struct {
+	Name        string            `json:"name"                  yaml:"name"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Labels      map[string]string `json:"labels"                yaml:"labels"`
+	Value       float64           `json:"value"                 yaml:"value"`
+	Counter     bool              `json:"counter,omitempty"     yaml:"counter,omitempty"`
+}
+
+//nolint:gochecknoglobals // replacer for the Prometheus text format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Samples returns the samples of the collected metrics, sorted by name and labels,
+// with the names prefixed by the namespace as they are exposed to Prometheus.
+func Samples(namespace string, rm *metricdata.ResourceMetrics) []Sample {
+	var samples []Sample
+
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			sample := Sample{Name: PrometheusName(namespace, m.Name), Description: m.Description}
+
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					sample.Labels, sample.Value = labels(dp.Attributes), float64(dp.Value)
+					samples = append(samples, sample)
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					sample.Labels, sample.Value = labels(dp.Attributes), dp.Value
+					samples = append(samples, sample)
+				}
+			case metricdata.Sum[int64]:
+				// Monotonic sums are exposed as counters, with the _total suffix.
+				if data.IsMonotonic {
+					sample.Name, sample.Counter = sample.Name+"_total", true
+				}
+
+				for _, dp := range data.DataPoints {
+					sample.Labels, sample.Value = labels(dp.Attributes), float64(dp.Value)
+					samples = append(samples, sample)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		if samples[i].Name != samples[j].Name {
+			return samples[i].Name < samples[j].Name
+		}
+
+		return labelString(samples[i].Labels) < labelString(samples[j].Labels)
+	})
+
+	return samples
+}
+
+// WriteText writes the samples in the Prometheus text exposition format.
+// The samples must be sorted by name, as returned by Samples.
+func WriteText(w io.Writer, samples []Sample) error {
+	var previous string
+
+	for _, sample := range samples {
+		if sample.Name != previous {
+			previous = sample.Name
+
+			if sample.Description != "" {
+				if _, err := fmt.Fprintf(w, "# HELP %s %s\n", sample.Name, sample.Description); err != nil {
+					return fmt.Errorf("error writing metrics: %w", err)
+				}
+			}
+
+			metricType := "gauge"
+			if sample.Counter {
+				metricType = "counter"
+			}
+
+			if _, err := fmt.Fprintf(w, "# TYPE %s %s\n", sample.Name, metricType); err != nil {
+				return fmt.Errorf("error writing metrics: %w", err)
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "%s%s %s\n",
+			sample.Name, labelString(sample.Labels), strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		if err != nil {
+			return fmt.Errorf("error writing metrics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func labels(set attribute.Set) map[string]string {
+	result := make(map[string]string, set.Len())
+
+	for _, kv := range set.ToSlice() {
+		result[string(kv.Key)] = kv.Value.Emit()
+	}
+
+	return result
+}
+
+// labelString formats the labels as a Prometheus label set, sorted by name.
+func labelString(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+labelValueEscaper.Replace(labels[name])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,76 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestSamples(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	_, err := meter.Int64ObservableGauge(metrics.FreeStorageName,
+		metric.WithDescription("The free storage."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(20, metric.WithAttributes(attribute.String("store", "b")))
+			o.Observe(10, metric.WithAttributes(attribute.String("store", `a"1`)))
+
+			return nil
+		}))
+	require.NoError(t, err)
+
+	_, err = meter.Float64ObservableGauge(metrics.HealthRatioName,
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(0.995)
+
+			return nil
+		}))
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	samples := metrics.Samples("test", &rm)
+
+	assert.Equal(t, []metrics.Sample{
+		{
+			Name:        "test_free_storage_bytes",
+			Description: "The free storage.",
+			Labels:      map[string]string{"store": `a"1`},
+			Value:       10,
+		},
+		{
+			Name:        "test_free_storage_bytes",
+			Description: "The free storage.",
+			Labels:      map[string]string{"store": "b"},
+			Value:       20,
+		},
+		{
+			Name:   "test_health_ratio",
+			Labels: map[string]string{},
+			Value:  0.995,
+		},
+	}, samples)
+
+	var text strings.Builder
+	require.NoError(t, metrics.WriteText(&text, samples))
+
+	assert.Equal(t, `# HELP test_free_storage_bytes The free storage.
+# TYPE test_free_storage_bytes gauge
+test_free_storage_bytes{store="a\"1"} 10
+test_free_storage_bytes{store="b"} 20
+# TYPE test_health_ratio gauge
+test_health_ratio 0.995
+`, text.String())
+}
@@ -0,0 +1,148 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type (
+	// Label represents a remote-write label.
+	Label struct {
+		Name  string
+		Value string
+	}
+
+	// Sample represents a remote-write sample, with the timestamp in milliseconds.
+	Sample struct {
+		Value     float64
+		Timestamp int64
+	}
+
+	// TimeSeries represents a remote-write time series.
+	TimeSeries struct {
+		Labels  []Label
+		Samples []Sample
+	}
+)
+
+// Field numbers of the prometheus.WriteRequest protobuf message and its children.
+const (
+	writeRequestTimeseries = 1
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+)
+
+// metricNameLabel is the label holding the name of the metric.
+const metricNameLabel = "__name__"
+
+// EncodeWriteRequest encodes the time series as a prometheus.WriteRequest protobuf message.
+func EncodeWriteRequest(series []TimeSeries) []byte {
+	var b []byte
+
+	for _, ts := range series {
+		b = protowire.AppendTag(b, writeRequestTimeseries, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeTimeSeries(ts))
+	}
+
+	return b
+}
+
+func encodeTimeSeries(ts TimeSeries) []byte {
+	var b []byte
+
+	for _, label := range ts.Labels {
+		var l []byte
+		l = protowire.AppendTag(l, labelName, protowire.BytesType)
+		l = protowire.AppendString(l, label.Name)
+		l = protowire.AppendTag(l, labelValue, protowire.BytesType)
+		l = protowire.AppendString(l, label.Value)
+
+		b = protowire.AppendTag(b, timeSeriesLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, l)
+	}
+
+	for _, sample := range ts.Samples {
+		var s []byte
+		s = protowire.AppendTag(s, sampleValue, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(sample.Value))
+		s = protowire.AppendTag(s, sampleTimestamp, protowire.VarintType)
+		s = protowire.AppendVarint(s, uint64(sample.Timestamp))
+
+		b = protowire.AppendTag(b, timeSeriesSamples, protowire.BytesType)
+		b = protowire.AppendBytes(b, s)
+	}
+
+	return b
+}
+
+// convert converts the gathered metric families to time series, adding the external labels to each series.
+// Only counters, gauges and untyped metrics are converted, which covers every metric of the exporter.
+func convert(families []*dto.MetricFamily, externalLabels map[string]string, timestamp int64) []TimeSeries {
+	var series []TimeSeries
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			var value float64
+
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+
+			ts := timestamp
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+
+			series = append(series, TimeSeries{
+				Labels:  labels(family.GetName(), m.GetLabel(), externalLabels),
+				Samples: []Sample{{Value: value, Timestamp: ts}},
+			})
+		}
+	}
+
+	return series
+}
+
+// labels returns the sorted labels of the series.
+// The labels of the metric take precedence over the external labels.
+func labels(name string, pairs []*dto.LabelPair, externalLabels map[string]string) []Label {
+	set := make(map[string]string, len(pairs)+len(externalLabels)+1)
+
+	for k, v := range externalLabels {
+		set[k] = v
+	}
+
+	for _, pair := range pairs {
+		set[pair.GetName()] = pair.GetValue()
+	}
+
+	set[metricNameLabel] = name
+
+	result := make([]Label, 0, len(set))
+	for k, v := range set {
+		result = append(result, Label{Name: k, Value: v})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
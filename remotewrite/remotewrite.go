@@ -0,0 +1,320 @@
+// Package remotewrite periodically pushes the exporter's metrics to a Prometheus remote-write endpoint.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// Config represents the configuration of the remote-write sender.
+	Config struct {
+		Enabled        bool              `mapstructure:"enabled"`
+		URL            string            `mapstructure:"url"`
+		BasicAuth      BasicAuth         `mapstructure:"basic_auth"`
+		BearerToken    string            `mapstructure:"bearer_token"`
+		Headers        map[string]string `mapstructure:"headers"`
+		ExternalLabels map[string]string `mapstructure:"external_labels"`
+		Interval       time.Duration     `mapstructure:"interval"`
+		Timeout        time.Duration     `mapstructure:"timeout"`
+		MaxRetries     int               `mapstructure:"max_retries"`
+		MinBackoff     time.Duration     `mapstructure:"min_backoff"`
+		MaxBackoff     time.Duration     `mapstructure:"max_backoff"`
+		QueueSize      int               `mapstructure:"queue_size"`
+	}
+
+	// BasicAuth represents the basic authentication credentials of the remote-write endpoint.
+	BasicAuth struct {
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password"`
+	}
+
+	// Sender gathers the metrics into a bounded queue of write requests and sends them to the endpoint in order.
+	// Requests that fail with a recoverable error stay in the queue and are retried on the next flush,
+	// and the oldest requests are dropped once the queue is full.
+	Sender struct {
+		config   Config
+		gatherer prometheus.Gatherer
+		client   *http.Client
+
+		mu    sync.Mutex
+		queue [][]byte
+	}
+
+	// recoverableError is an error after which the request may succeed when retried.
+	recoverableError struct {
+		err error
+	}
+)
+
+const (
+	// DefaultInterval is the default interval between remote-write requests.
+	DefaultInterval = time.Minute
+
+	// DefaultTimeout is the default timeout of a remote-write request.
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultMaxRetries is the default number of times a request is retried before it is left for the next flush.
+	DefaultMaxRetries = 3
+
+	// DefaultMinBackoff is the default initial delay between retries.
+	DefaultMinBackoff = 30 * time.Millisecond
+
+	// DefaultMaxBackoff is the default maximum delay between retries.
+	DefaultMaxBackoff = 5 * time.Second
+
+	// DefaultQueueSize is the default number of write requests kept while the endpoint is unavailable.
+	DefaultQueueSize = 100
+
+	userAgent = "zadara-exporter"
+)
+
+var (
+	// ErrNoURL is returned when the remote-write URL is not configured.
+	ErrNoURL = errors.New("remote-write url is not set")
+
+	// ErrRequestFailed is returned when the endpoint rejects a request.
+	ErrRequestFailed = errors.New("remote-write request failed")
+)
+
+func (e *recoverableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *recoverableError) Unwrap() error {
+	return e.err
+}
+
+// New creates a new sender for the metrics of the gatherer.
+// If the interval, timeout, retries, backoff or queue size are not set, their defaults are used.
+func New(config Config, gatherer prometheus.Gatherer) (*Sender, error) {
+	if config.URL == "" {
+		return nil, ErrNoURL
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+
+	return &Sender{
+		config:   config,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// Run collects and flushes the metrics every interval until the context is done.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Collect(); err != nil {
+			slog.Error("error collecting metrics for remote-write", "error", err)
+		}
+
+		if err := s.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("error sending metrics to remote-write endpoint",
+				"url", s.config.URL,
+				"pending", s.Len(),
+				"error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect gathers the metrics and adds them to the queue as a single write request.
+func (s *Sender) Collect() error {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %w", err)
+	}
+
+	series := convert(families, s.config.ExternalLabels, time.Now().UnixMilli())
+	if len(series) == 0 {
+		return nil
+	}
+
+	s.enqueue(snappy.Encode(nil, EncodeWriteRequest(series)))
+
+	return nil
+}
+
+// Len returns the number of write requests waiting to be sent.
+func (s *Sender) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.queue)
+}
+
+// Flush sends the queued write requests, oldest first.
+// Requests rejected by the endpoint are dropped, while a request that still fails with a recoverable error
+// after retrying stops the flush and is kept for the next one.
+func (s *Sender) Flush(ctx context.Context) error {
+	var errs []error
+
+	for {
+		payload, ok := s.peek()
+		if !ok {
+			return errors.Join(errs...)
+		}
+
+		err := s.sendWithRetries(ctx, payload)
+
+		var recoverable *recoverableError
+		if errors.As(err, &recoverable) {
+			return errors.Join(append(errs, err)...)
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		s.pop()
+	}
+}
+
+func (s *Sender) enqueue(payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) >= s.config.QueueSize {
+		dropped := len(s.queue) - s.config.QueueSize + 1
+		s.queue = s.queue[dropped:]
+
+		slog.Warn("remote-write queue is full, dropping the oldest requests", "dropped", dropped)
+	}
+
+	s.queue = append(s.queue, payload)
+}
+
+func (s *Sender) peek() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+
+	return s.queue[0], true
+}
+
+func (s *Sender) pop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) > 0 {
+		s.queue = s.queue[1:]
+	}
+}
+
+// sendWithRetries sends the payload, retrying recoverable errors with exponential backoff.
+func (s *Sender) sendWithRetries(ctx context.Context, payload []byte) error {
+	backoff := s.config.MinBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := s.send(ctx, payload)
+
+		var recoverable *recoverableError
+		if !errors.As(err, &recoverable) || attempt >= s.config.MaxRetries {
+			return err
+		}
+
+		slog.Debug("retrying remote-write request", "attempt", attempt+1, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return &recoverableError{err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, s.config.MaxBackoff) //nolint:mnd // exponential backoff
+	}
+}
+
+// send sends a single write request to the endpoint.
+func (s *Sender) send(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating remote-write request: %w", err)
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	switch {
+	case s.config.BasicAuth.Username != "":
+		req.SetBasicAuth(s.config.BasicAuth.Username, s.config.BasicAuth.Password)
+	case s.config.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+s.config.BearerToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &recoverableError{err: fmt.Errorf("error sending remote-write request: %w", err)}
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("error closing response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode/100 == 2 { //nolint:mnd // 2xx status codes
+		return nil
+	}
+
+	const maxErrorBody = 256
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err = fmt.Errorf("%w: %s: %s", ErrRequestFailed, resp.Status, bytes.TrimSpace(body))
+
+	// Server errors and rate limiting are retried, other client errors will never succeed.
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return &recoverableError{err: err}
+	}
+
+	return err
+}
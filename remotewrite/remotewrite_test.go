@@ -0,0 +1,269 @@
+package remotewrite_test
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/krystal/zadara-exporter/remotewrite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// receiver is a remote-write endpoint that decodes the requests it receives.
+type receiver struct {
+	mu     sync.Mutex
+	series []remotewrite.TimeSeries
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	compressed, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	body, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.series = append(r.series, decodeWriteRequest(body)...)
+}
+
+func (r *receiver) received() []remotewrite.TimeSeries {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.series
+}
+
+// fields calls fn for each field of the protobuf message.
+func fields(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		b = b[fn(num, typ, b):]
+	}
+}
+
+func decodeWriteRequest(b []byte) []remotewrite.TimeSeries {
+	var series []remotewrite.TimeSeries
+
+	fields(b, func(_ protowire.Number, _ protowire.Type, b []byte) int {
+		msg, n := protowire.ConsumeBytes(b)
+
+		var ts remotewrite.TimeSeries
+
+		fields(msg, func(num protowire.Number, _ protowire.Type, b []byte) int {
+			inner, n := protowire.ConsumeBytes(b)
+
+			if num == 1 {
+				var label remotewrite.Label
+
+				fields(inner, func(num protowire.Number, _ protowire.Type, b []byte) int {
+					v, n := protowire.ConsumeString(b)
+					if num == 1 {
+						label.Name = v
+					} else {
+						label.Value = v
+					}
+
+					return n
+				})
+
+				ts.Labels = append(ts.Labels, label)
+			} else {
+				var sample remotewrite.Sample
+
+				fields(inner, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					if typ == protowire.Fixed64Type {
+						v, n := protowire.ConsumeFixed64(b)
+						sample.Value = math.Float64frombits(v)
+
+						return n
+					}
+
+					v, n := protowire.ConsumeVarint(b)
+					sample.Timestamp = int64(v)
+
+					return n
+				})
+
+				ts.Samples = append(ts.Samples, sample)
+			}
+
+			return n
+		})
+
+		series = append(series, ts)
+
+		return n
+	})
+
+	return series
+}
+
+func newGatherer(t *testing.T) *prometheus.Registry {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "zadara_free_storage"}, []string{"store"})
+	gauge.WithLabelValues("store1@cc1").Set(1024)
+	require.NoError(t, registry.Register(gauge))
+
+	return registry
+}
+
+func TestSender_Flush(t *testing.T) {
+	t.Parallel()
+
+	recv := &receiver{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+
+		recv.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	sender, err := remotewrite.New(remotewrite.Config{
+		URL:            server.URL,
+		BasicAuth:      remotewrite.BasicAuth{Username: "user", Password: "pass"},
+		ExternalLabels: map[string]string{"instance": "london", "store": "ignored"},
+	}, newGatherer(t))
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Collect())
+	require.NoError(t, sender.Flush(context.Background()))
+	assert.Equal(t, 0, sender.Len())
+
+	series := recv.received()
+	require.Len(t, series, 1)
+
+	assert.Equal(t, []remotewrite.Label{
+		{Name: "__name__", Value: "zadara_free_storage"},
+		{Name: "instance", Value: "london"},
+		{Name: "store", Value: "store1@cc1"},
+	}, series[0].Labels)
+	require.Len(t, series[0].Samples, 1)
+	assert.InDelta(t, 1024, series[0].Samples[0].Value, 0)
+	assert.WithinDuration(t, time.Now(), time.UnixMilli(series[0].Samples[0].Timestamp), time.Minute)
+}
+
+func TestSender_FlushRetries(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+
+	recv := &receiver{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		recv.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	sender, err := remotewrite.New(remotewrite.Config{
+		URL:         server.URL,
+		BearerToken: "token",
+		MinBackoff:  time.Millisecond,
+	}, newGatherer(t))
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Collect())
+	require.NoError(t, sender.Flush(context.Background()))
+
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Len(t, recv.received(), 1)
+}
+
+func TestSender_FlushKeepsQueueWhenUnavailable(t *testing.T) {
+	t.Parallel()
+
+	var available atomic.Bool
+
+	recv := &receiver{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		recv.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	sender, err := remotewrite.New(remotewrite.Config{
+		URL:        server.URL,
+		MaxRetries: 1,
+		MinBackoff: time.Millisecond,
+		QueueSize:  2,
+	}, newGatherer(t))
+	require.NoError(t, err)
+
+	for range 3 {
+		require.NoError(t, sender.Collect())
+		require.ErrorIs(t, sender.Flush(context.Background()), remotewrite.ErrRequestFailed)
+	}
+
+	// The oldest request was dropped once the queue was full.
+	assert.Equal(t, 2, sender.Len())
+
+	available.Store(true)
+
+	require.NoError(t, sender.Flush(context.Background()))
+	assert.Equal(t, 0, sender.Len())
+	assert.Len(t, recv.received(), 2)
+}
+
+func TestSender_FlushDropsRejectedRequests(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sender, err := remotewrite.New(remotewrite.Config{URL: server.URL}, newGatherer(t))
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Collect())
+	require.ErrorIs(t, sender.Flush(context.Background()), remotewrite.ErrRequestFailed)
+	assert.Equal(t, 0, sender.Len())
+}
+
+func TestNew_NoURL(t *testing.T) {
+	t.Parallel()
+
+	_, err := remotewrite.New(remotewrite.Config{}, prometheus.NewRegistry())
+	require.ErrorIs(t, err, remotewrite.ErrNoURL)
+}
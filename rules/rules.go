@@ -0,0 +1,300 @@
+// Package rules generates Prometheus recording and alerting rules for the zadara-exporter metrics.
+package rules
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krystal/zadara-exporter/metrics"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// File represents a Prometheus rule file.
+	File struct {
+		Groups []*Group `yaml:"groups"`
+	}
+
+	// Group represents a group of Prometheus rules.
+	Group struct {
+		Name  string  `yaml:"name"`
+		Rules []*Rule `yaml:"rules"`
+	}
+
+	// Rule represents a Prometheus recording or alerting rule.
+	Rule struct {
+		Record      string            `yaml:"record,omitempty"`
+		Alert       string            `yaml:"alert,omitempty"`
+		Expr        string            `yaml:"expr"`
+		For         string            `yaml:"for,omitempty"`
+		Labels      map[string]string `yaml:"labels,omitempty"`
+		Annotations map[string]string `yaml:"annotations,omitempty"`
+	}
+
+	// PrometheusRule represents a Prometheus Operator PrometheusRule resource.
+	PrometheusRule struct {
+		APIVersion string            `yaml:"apiVersion"`
+		Kind       string            `yaml:"kind"`
+		Metadata   map[string]string `yaml:"metadata"`
+		Spec       *File             `yaml:"spec"`
+	}
+
+	// Config represents the configuration used to generate the rules.
+	Config struct {
+		// Namespace is the namespace the metrics are exported with.
+		Namespace string
+
+		// Job is a regular expression matching the job label of the exporter's scrape target.
+		Job string
+
+		// FreeCapacityThreshold is the ratio of free capacity below which a policy is alerted on.
+		FreeCapacityThreshold float64
+
+		// HealthThreshold is the health ratio below which a policy is alerted on.
+		HealthThreshold float64
+
+		// DegradedThreshold is the degraded ring balance ratio above which a policy is alerted on.
+		DegradedThreshold float64
+
+		// For is how long an alert condition must hold before the alert fires.
+		For time.Duration
+
+		// Labels is the configuration of the built-in labels the metrics are exported with.
+		Labels metrics.LabelConfig
+	}
+)
+
+const (
+	// DefaultJob is the default regular expression matching the exporter's job label.
+	DefaultJob = ".*zadara-exporter.*"
+
+	// DefaultFreeCapacityThreshold is the default ratio of free capacity to alert below.
+	DefaultFreeCapacityThreshold = 0.1
+
+	// DefaultHealthThreshold is the default health ratio to alert below.
+	DefaultHealthThreshold = 1.0
+
+	// DefaultDegradedThreshold is the default degraded ring balance ratio to alert above.
+	DefaultDegradedThreshold = 0.0
+
+	// DefaultFor is the default duration an alert condition must hold for.
+	DefaultFor = 15 * time.Minute
+
+	severityWarning  = "warning"
+	severityCritical = "critical"
+)
+
+// Generate returns the recording and alerting rules for the given configuration.
+// If the namespace or job are empty, their defaults are used.
+func Generate(config Config) *File {
+	if config.Namespace == "" {
+		config.Namespace = metrics.DefaultNamespace
+	}
+
+	if config.Job == "" {
+		config.Job = DefaultJob
+	}
+
+	return &File{
+		Groups: []*Group{
+			recordingRules(config),
+			alertingRules(config),
+		},
+	}
+}
+
+// WrapPrometheusRule wraps the rule file in a PrometheusRule resource with the given name.
+func WrapPrometheusRule(file *File, name string) *PrometheusRule {
+	return &PrometheusRule{
+		APIVersion: "monitoring.coreos.com/v1",
+		Kind:       "PrometheusRule",
+		Metadata:   map[string]string{"name": name},
+		Spec:       file,
+	}
+}
+
+// Write encodes the value as YAML to the writer.
+func Write(w io.Writer, v any) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2) //nolint:mnd // the conventional indent for Kubernetes manifests
+
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("error encoding rules: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("error encoding rules: %w", err)
+	}
+
+	return nil
+}
+
+// recordName returns the name of a recording rule following the level:metric:operations convention.
+func recordName(config Config, level, name, operation string) string {
+	return level + ":" + metrics.PrometheusName(config.Namespace, name) + ":" + operation
+}
+
+// labelRef returns the template referencing the value of the built-in label in an annotation.
+func labelRef(config Config, name string) string {
+	if exported, ok := config.Labels.LabelName(name); ok {
+		name = exported
+	}
+
+	return "{{ $labels." + name + " }}"
+}
+
+func recordingRules(config Config) *Group {
+	used := metrics.PrometheusName(config.Namespace, metrics.UsedStorageName)
+	free := metrics.PrometheusName(config.Namespace, metrics.FreeStorageName)
+	storeLabels := strings.Join(
+		config.Labels.LabelNames(metrics.NameLabel, metrics.CloudNameLabel, metrics.StoreLabel), ", ")
+
+	return &Group{
+		Name: config.Namespace + ".rules",
+		Rules: []*Rule{
+			{
+				Record: recordName(config, "policy", "storage_utilisation", "ratio"),
+				Expr:   fmt.Sprintf("%s / (%s + %s)", used, used, free),
+			},
+			{
+				Record: recordName(config, "store", metrics.UsedStorageName, "sum"),
+				Expr:   fmt.Sprintf("sum by (%s) (%s)", storeLabels, used),
+			},
+			{
+				Record: recordName(config, "store", metrics.FreeStorageName, "sum"),
+				Expr:   fmt.Sprintf("sum by (%s) (%s)", storeLabels, free),
+			},
+			{
+				Record: recordName(config, "store", "storage_utilisation", "ratio"),
+				Expr: fmt.Sprintf("%s / (%s + %s)",
+					recordName(config, "store", metrics.UsedStorageName, "sum"),
+					recordName(config, "store", metrics.UsedStorageName, "sum"),
+					recordName(config, "store", metrics.FreeStorageName, "sum"),
+				),
+			},
+		},
+	}
+}
+
+func alertingRules(config Config) *Group {
+	forDuration := promDuration(config.For)
+	name := labelRef(config, metrics.NameLabel)
+	store := labelRef(config, metrics.StoreLabel)
+	policy := labelRef(config, metrics.PolicyNameLabel)
+
+	return &Group{
+		Name: config.Namespace + ".alerts",
+		Rules: []*Rule{
+			{
+				Alert: "ZadaraExporterDown",
+				Expr:  fmt.Sprintf(`up{job=~%q} == 0`, config.Job),
+				For:   forDuration,
+				Labels: map[string]string{
+					"severity": severityCritical,
+				},
+				Annotations: map[string]string{
+					"summary":     "Zadara exporter is down",
+					"description": "Prometheus has failed to scrape the Zadara exporter {{ $labels.instance }}.",
+				},
+			},
+			{
+				Alert: "ZadaraTargetAuthFailed",
+				Expr: fmt.Sprintf("%s == 1",
+					metrics.PrometheusName(config.Namespace, metrics.TargetAuthFailedName)),
+				For: forDuration,
+				Labels: map[string]string{
+					"severity": severityCritical,
+				},
+				Annotations: map[string]string{
+					"summary": "Zadara Command Center rejected the token of the target",
+					"description": "The Command Center of target " + name + " rejected its token, " +
+						"so its storage metrics are not collected.",
+				},
+			},
+			{
+				Alert: "ZadaraRingBalanceDegraded",
+				Expr: fmt.Sprintf("%s > %s",
+					metrics.PrometheusName(config.Namespace, metrics.RingBalanceDegradedRatioName),
+					number(config.DegradedThreshold)),
+				For: forDuration,
+				Labels: map[string]string{
+					"severity": severityWarning,
+				},
+				Annotations: map[string]string{
+					"summary": "Zadara storage policy ring balance is degraded",
+					"description": "{{ $value | humanizePercentage }} of the ring of storage policy " +
+						policy + " in store " + store + " is degraded.",
+				},
+			},
+			{
+				Alert: "ZadaraRingBalanceCritical",
+				Expr: fmt.Sprintf("%s > 0",
+					metrics.PrometheusName(config.Namespace, metrics.RingBalanceCriticalRatioName)),
+				For: forDuration,
+				Labels: map[string]string{
+					"severity": severityCritical,
+				},
+				Annotations: map[string]string{
+					"summary": "Zadara storage policy ring balance is critical",
+					"description": "{{ $value | humanizePercentage }} of the ring of storage policy " +
+						policy + " in store " + store + " is critical.",
+				},
+			},
+			{
+				Alert: "ZadaraLowFreeCapacity",
+				Expr: fmt.Sprintf("1 - %s < %s",
+					recordName(config, "policy", "storage_utilisation", "ratio"),
+					number(config.FreeCapacityThreshold)),
+				For: forDuration,
+				Labels: map[string]string{
+					"severity": severityWarning,
+				},
+				Annotations: map[string]string{
+					"summary": "Zadara storage policy is running out of free capacity",
+					"description": "Storage policy " + policy + " in store " + store + " " +
+						"has {{ $value | humanizePercentage }} free capacity left.",
+				},
+			},
+			{
+				Alert: "ZadaraPolicyHealthLow",
+				Expr: fmt.Sprintf("%s < %s",
+					metrics.PrometheusName(config.Namespace, metrics.HealthRatioName),
+					number(config.HealthThreshold)),
+				For: forDuration,
+				Labels: map[string]string{
+					"severity": severityWarning,
+				},
+				Annotations: map[string]string{
+					"summary": "Zadara storage policy health is low",
+					"description": "Storage policy " + policy + " in store " + store + " " +
+						"is {{ $value | humanizePercentage }} healthy.",
+				},
+			},
+		},
+	}
+}
+
+// promDuration formats the duration as a Prometheus duration, rounded up to the millisecond
+// as that is the finest unit Prometheus supports.
+func promDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+
+	switch {
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	default:
+		return fmt.Sprintf("%dms", (d+time.Millisecond-1)/time.Millisecond)
+	}
+}
+
+// number formats the value for use in a PromQL expression.
+func number(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,103 @@
+package rules_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/metrics"
+	"github.com/krystal/zadara-exporter/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	file := rules.Generate(rules.Config{
+		Namespace:             "storage",
+		FreeCapacityThreshold: 0.2,
+		HealthThreshold:       0.95,
+		For:                   5 * time.Minute,
+	})
+
+	require.Len(t, file.Groups, 2)
+
+	records := map[string]string{}
+	for _, rule := range file.Groups[0].Rules {
+		records[rule.Record] = rule.Expr
+	}
+
+	assert.Equal(t, "storage_used_storage_bytes / (storage_used_storage_bytes + storage_free_storage_bytes)",
+		records["policy:storage_storage_utilisation:ratio"])
+	assert.Equal(t, "sum by (name, cloud_name, store) (storage_used_storage_bytes)",
+		records["store:storage_used_storage_bytes:sum"])
+
+	alerts := map[string]*rules.Rule{}
+	for _, rule := range file.Groups[1].Rules {
+		alerts[rule.Alert] = rule
+	}
+
+	assert.Equal(t, `up{job=~".*zadara-exporter.*"} == 0`, alerts["ZadaraExporterDown"].Expr)
+	assert.Equal(t, "1 - policy:storage_storage_utilisation:ratio < 0.2", alerts["ZadaraLowFreeCapacity"].Expr)
+	assert.Equal(t, "storage_health_ratio < 0.95", alerts["ZadaraPolicyHealthLow"].Expr)
+	assert.Equal(t, "storage_ring_balance_degraded_ratio > 0", alerts["ZadaraRingBalanceDegraded"].Expr)
+	assert.Equal(t, "storage_target_auth_failed == 1", alerts["ZadaraTargetAuthFailed"].Expr)
+	assert.Equal(t, "5m", alerts["ZadaraRingBalanceDegraded"].For)
+}
+
+func TestGenerate_Labels(t *testing.T) {
+	t.Parallel()
+
+	file := rules.Generate(rules.Config{
+		Labels: metrics.LabelConfig{
+			Drop:   []string{metrics.CloudNameLabel},
+			Rename: map[string]string{metrics.NameLabel: "target", metrics.PolicyNameLabel: "policy"},
+		},
+		For: 1500 * time.Millisecond,
+	})
+
+	records := map[string]string{}
+	for _, rule := range file.Groups[0].Rules {
+		records[rule.Record] = rule.Expr
+	}
+
+	assert.Equal(t, "sum by (target, store) (zadara_used_storage_bytes)",
+		records["store:zadara_used_storage_bytes:sum"])
+
+	alerts := map[string]*rules.Rule{}
+	for _, rule := range file.Groups[1].Rules {
+		alerts[rule.Alert] = rule
+	}
+
+	assert.Contains(t, alerts["ZadaraTargetAuthFailed"].Annotations["description"], "{{ $labels.target }}")
+	assert.Contains(t, alerts["ZadaraPolicyHealthLow"].Annotations["description"], "{{ $labels.policy }}")
+	assert.Equal(t, "1500ms", alerts["ZadaraPolicyHealthLow"].For)
+}
+
+func TestWrite_PrometheusRule(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	require.NoError(t, rules.Write(&buf, rules.WrapPrometheusRule(rules.Generate(rules.Config{}), "zadara")))
+
+	var doc struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Groups []struct {
+				Name string `yaml:"name"`
+			} `yaml:"groups"`
+		} `yaml:"spec"`
+	}
+
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "PrometheusRule", doc.Kind)
+	assert.Equal(t, "zadara", doc.Metadata.Name)
+	require.Len(t, doc.Spec.Groups, 2)
+	assert.Equal(t, "zadara.rules", doc.Spec.Groups[0].Name)
+}
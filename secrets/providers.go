@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type (
+	// ExecConfig configures the exec provider.
+	ExecConfig struct {
+		// Timeout is how long the commands can run for. (default: 10s)
+		Timeout time.Duration `mapstructure:"timeout"`
+	}
+
+	// ExecProvider retrieves secrets from the output of commands, such as ${exec:/usr/bin/get-token london}.
+	ExecProvider struct {
+		timeout time.Duration
+	}
+)
+
+// DefaultExecTimeout is the default time the commands of the exec provider can run for.
+const DefaultExecTimeout = 10 * time.Second
+
+// ErrEmptyCommand is returned when an exec reference has no command.
+var ErrEmptyCommand = errors.New("empty command")
+
+// envSecret returns the value of the environment variable, such as ${env:ZADARA_LONDON_TOKEN}.
+func envSecret(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, name)
+	}
+
+	return value, nil
+}
+
+// fileSecret returns the contents of the file without surrounding whitespace, such as ${file:/run/secrets/token}.
+func fileSecret(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// NewExecProvider returns a provider running the commands with the configuration.
+func NewExecProvider(cfg ExecConfig) *ExecProvider {
+	p := &ExecProvider{timeout: cfg.Timeout}
+	if p.timeout <= 0 {
+		p.timeout = DefaultExecTimeout
+	}
+
+	return p
+}
+
+// Secret runs the command, split into its arguments on whitespace without a shell, and returns its output
+// without surrounding whitespace.
+func (p *ExecProvider) Secret(ctx context.Context, command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", ErrEmptyCommand
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // only configured targets reference secrets
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error running %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,155 @@
+// Package secrets resolves the tokens of the targets that reference a secret, such as ${vault:secret/zadara#london},
+// with the provider of the reference's scheme.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+type (
+	// Provider retrieves secrets by reference, such as the name of an environment variable or the path of a file.
+	Provider interface {
+		Secret(ctx context.Context, ref string) (string, error)
+	}
+
+	// ProviderFunc is a function implementing Provider.
+	ProviderFunc func(ctx context.Context, ref string) (string, error)
+
+	// Config configures the resolution of secrets.
+	Config struct {
+		// CacheTTL is how long resolved secrets are reused for. They are resolved again sooner if rejected.
+		// (default: 5m)
+		CacheTTL time.Duration `mapstructure:"cache_ttl"`
+
+		// Exec configures the exec provider.
+		Exec ExecConfig `mapstructure:"exec"`
+
+		// Vault configures the HashiCorp Vault provider, which is enabled if it has an address.
+		Vault VaultConfig `mapstructure:"vault"`
+	}
+
+	// Resolver resolves the secrets referenced by tokens with the providers of their schemes, caching them.
+	Resolver struct {
+		providers map[string]Provider
+		cacheTTL  time.Duration
+		now       func() time.Time
+
+		mu    sync.Mutex
+		cache map[string]cachedSecret
+	}
+
+	// cachedSecret is a resolved secret and when it expires.
+	cachedSecret struct {
+		value   string
+		expires time.Time
+	}
+)
+
+// DefaultCacheTTL is the default time resolved secrets are reused for.
+const DefaultCacheTTL = 5 * time.Minute
+
+var (
+	// ErrUnknownProvider is returned when a token references a secret with a scheme that has no provider.
+	ErrUnknownProvider = errors.New("unknown secret provider")
+
+	// ErrSecretNotFound is returned when a provider has no secret for the reference.
+	ErrSecretNotFound = errors.New("secret not found")
+
+	// referencePattern matches a token that references a secret, capturing the scheme and reference.
+	referencePattern = regexp.MustCompile(`^\$\{([a-z]+):(.+)\}$`)
+)
+
+// Secret calls f(ctx, ref).
+func (f ProviderFunc) Secret(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// ParseReference returns the scheme and reference of a token referencing a secret, such as
+// ${vault:secret/zadara#london}, and whether the token is a reference.
+func ParseReference(token string) (string, string, bool) {
+	match := referencePattern.FindStringSubmatch(token)
+	if match == nil {
+		return "", "", false
+	}
+
+	return match[1], match[2], true
+}
+
+// NewResolver returns a resolver with the built-in providers for the configuration:
+// env, file and exec, and vault if it has an address.
+func NewResolver(cfg Config) *Resolver {
+	r := &Resolver{
+		providers: map[string]Provider{
+			"env":  ProviderFunc(envSecret),
+			"file": ProviderFunc(fileSecret),
+			"exec": NewExecProvider(cfg.Exec),
+		},
+		cacheTTL: cfg.CacheTTL,
+		now:      time.Now,
+		cache:    map[string]cachedSecret{},
+	}
+
+	if r.cacheTTL <= 0 {
+		r.cacheTTL = DefaultCacheTTL
+	}
+
+	if vault := NewVaultProvider(cfg.Vault); vault != nil {
+		r.providers["vault"] = vault
+	}
+
+	return r
+}
+
+// Register adds the provider of the scheme, replacing any provider of the scheme.
+func (r *Resolver) Register(scheme string, provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.providers[scheme] = provider
+}
+
+// Token returns the secret the token references, or the token itself if it is not a reference.
+func (r *Resolver) Token(ctx context.Context, token string) (string, error) {
+	scheme, ref, ok := ParseReference(token)
+	if !ok {
+		return token, nil
+	}
+
+	r.mu.Lock()
+	cached, found := r.cache[token]
+	provider, known := r.providers[scheme]
+	r.mu.Unlock()
+
+	if found && r.now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	if !known {
+		return "", fmt.Errorf("%w: %q", ErrUnknownProvider, scheme)
+	}
+
+	value, err := provider.Secret(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s secret: %w", scheme, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cache[token] = cachedSecret{value: value, expires: r.now().Add(r.cacheTTL)}
+
+	return value, nil
+}
+
+// Invalidate removes the cached secret of the token, so it is resolved again, such as when it was rejected.
+func (r *Resolver) Invalidate(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.cache, token)
+}
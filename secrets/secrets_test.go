@@ -0,0 +1,96 @@
+package secrets_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	t.Parallel()
+
+	scheme, ref, ok := secrets.ParseReference("${vault:secret/zadara#london}")
+	assert.True(t, ok)
+	assert.Equal(t, "vault", scheme)
+	assert.Equal(t, "secret/zadara#london", ref)
+
+	for _, token := range []string{"plain-token", "${vault}", "prefix ${env:TOKEN}", "${:TOKEN}"} {
+		_, _, ok := secrets.ParseReference(token)
+		assert.False(t, ok, token)
+	}
+}
+
+func TestResolver_BuiltinProviders(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("file-token\n"), 0o600))
+
+	r := secrets.NewResolver(secrets.Config{})
+
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{token: "plain-token", want: "plain-token"},
+		{token: "${env:PATH}", want: os.Getenv("PATH")},
+		{token: "${file:" + path + "}", want: "file-token"},
+		{token: "${exec:echo exec-token}", want: "exec-token"},
+	}
+
+	for _, tt := range tests {
+		got, err := r.Token(context.Background(), tt.token)
+		require.NoError(t, err, tt.token)
+		assert.Equal(t, tt.want, got, tt.token)
+	}
+}
+
+func TestResolver_Errors(t *testing.T) {
+	t.Parallel()
+
+	r := secrets.NewResolver(secrets.Config{})
+
+	_, err := r.Token(context.Background(), "${aws:zadara}")
+	require.ErrorIs(t, err, secrets.ErrUnknownProvider)
+
+	_, err = r.Token(context.Background(), "${env:ZADARA_EXPORTER_TEST_UNSET}")
+	require.ErrorIs(t, err, secrets.ErrSecretNotFound)
+
+	_, err = r.Token(context.Background(), "${file:"+filepath.Join(t.TempDir(), "missing")+"}")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = r.Token(context.Background(), "${exec:false}")
+	require.Error(t, err)
+}
+
+func TestResolver_Cache(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+
+	r := secrets.NewResolver(secrets.Config{})
+	r.Register("test", secrets.ProviderFunc(func(_ context.Context, ref string) (string, error) {
+		calls++
+
+		return ref + "-" + strconv.Itoa(calls), nil
+	}))
+
+	for range 3 {
+		token, err := r.Token(context.Background(), "${test:london}")
+		require.NoError(t, err)
+		assert.Equal(t, "london-1", token)
+	}
+
+	// Invalidated secrets are resolved again.
+	r.Invalidate("${test:london}")
+
+	token, err := r.Token(context.Background(), "${test:london}")
+	require.NoError(t, err)
+	assert.Equal(t, "london-2", token)
+}
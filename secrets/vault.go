@@ -0,0 +1,171 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type (
+	// VaultConfig configures the HashiCorp Vault provider.
+	VaultConfig struct {
+		// Address is the address of the Vault server. (default: $VAULT_ADDR)
+		Address string `mapstructure:"address"`
+
+		// Token is the Vault token. (default: $VAULT_TOKEN)
+		Token string `mapstructure:"token"`
+
+		// TokenFile is the file the Vault token is read from on each request, in place of Token.
+		TokenFile string `mapstructure:"token_file"`
+
+		// Namespace is the Vault Enterprise namespace of the secrets.
+		Namespace string `mapstructure:"namespace"`
+
+		// Timeout is how long to wait for the secrets. (default: 10s)
+		Timeout time.Duration `mapstructure:"timeout"`
+	}
+
+	// VaultProvider retrieves secrets from the KV version 2 secrets engine of HashiCorp Vault,
+	// such as ${vault:secret/zadara#london} for the london key of the zadara secret of the secret mount.
+	VaultProvider struct {
+		address   string
+		token     string
+		tokenFile string
+		namespace string
+		client    *http.Client
+	}
+
+	// vaultResponse represents the response of the KV version 2 read secret API.
+	vaultResponse struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+)
+
+const (
+	// DefaultVaultTimeout is the default timeout of the requests to Vault.
+	DefaultVaultTimeout = 10 * time.Second
+
+	// DefaultVaultKey is the key of the secret used if the reference has none.
+	DefaultVaultKey = "token"
+
+	// maxVaultResponseSize is the maximum size of the responses of Vault.
+	maxVaultResponseSize = 1 << 20
+)
+
+var (
+	// ErrInvalidVaultReference is returned when a Vault reference has no mount or path.
+	ErrInvalidVaultReference = errors.New("invalid vault reference, expected mount/path#key")
+
+	// ErrVaultResponse is returned when Vault does not respond with the secret.
+	ErrVaultResponse = errors.New("vault error")
+)
+
+// NewVaultProvider returns a Vault provider with the configuration, defaulting to the address and token of
+// the VAULT_ADDR and VAULT_TOKEN environment variables, or nil if there is no address.
+func NewVaultProvider(cfg VaultConfig) *VaultProvider {
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+
+	if cfg.Address == "" {
+		return nil
+	}
+
+	if cfg.Token == "" && cfg.TokenFile == "" {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultVaultTimeout
+	}
+
+	return &VaultProvider{
+		address:   strings.TrimSuffix(cfg.Address, "/"),
+		token:     cfg.Token,
+		tokenFile: cfg.TokenFile,
+		namespace: cfg.Namespace,
+		client:    &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// vaultToken returns the Vault token, reading the token file if set so it can be renewed by an agent.
+func (p *VaultProvider) vaultToken(ctx context.Context) (string, error) {
+	if p.tokenFile == "" {
+		return p.token, nil
+	}
+
+	return fileSecret(ctx, p.tokenFile)
+}
+
+// Secret reads the key of the secret, of the form mount/path#key, from the KV version 2 secrets engine.
+func (p *VaultProvider) Secret(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		key = DefaultVaultKey
+	}
+
+	mount, path, ok := strings.Cut(path, "/")
+	if !ok || mount == "" || path == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidVaultReference, ref)
+	}
+
+	token, err := p.vaultToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint, err := url.JoinPath(p.address, "v1", mount, "data", path)
+	if err != nil {
+		return "", fmt.Errorf("error creating vault URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating vault request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", token)
+
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting vault secret: %w", err)
+	}
+
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			slog.Error("error closing response body", "error", err)
+		}
+	}()
+
+	var body vaultResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxVaultResponseSize)).Decode(&body); err != nil &&
+		res.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("error decoding vault response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s: %s", ErrVaultResponse, res.Status, strings.Join(body.Errors, ", "))
+	}
+
+	value, ok := body.Data.Data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: no key %q in %s/%s", ErrSecretNotFound, key, mount, path)
+	}
+
+	return value, nil
+}
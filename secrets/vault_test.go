@@ -0,0 +1,87 @@
+package secrets_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVaultStub returns a server stubbing the KV version 2 read secret API of Vault.
+func newVaultStub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+
+			return
+		}
+
+		assert.Equal(t, "team", r.Header.Get("X-Vault-Namespace"))
+
+		if r.URL.Path != "/v1/secret/data/zadara/tokens" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{
+			"data": {
+				"data": {"london": "london-token", "token": "default-token"},
+				"metadata": {"version": 3}
+			}
+		}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestVaultProvider(t *testing.T) {
+	t.Parallel()
+
+	server := newVaultStub(t)
+
+	r := secrets.NewResolver(secrets.Config{Vault: secrets.VaultConfig{
+		Address:   server.URL,
+		Token:     "vault-token",
+		Namespace: "team",
+	}})
+
+	token, err := r.Token(context.Background(), "${vault:secret/zadara/tokens#london}")
+	require.NoError(t, err)
+	assert.Equal(t, "london-token", token)
+
+	token, err = r.Token(context.Background(), "${vault:secret/zadara/tokens}")
+	require.NoError(t, err)
+	assert.Equal(t, "default-token", token)
+
+	_, err = r.Token(context.Background(), "${vault:secret/zadara/tokens#paris}")
+	require.ErrorIs(t, err, secrets.ErrSecretNotFound)
+
+	_, err = r.Token(context.Background(), "${vault:secret/zadara/other}")
+	require.ErrorIs(t, err, secrets.ErrVaultResponse)
+
+	_, err = r.Token(context.Background(), "${vault:secret}")
+	require.ErrorIs(t, err, secrets.ErrInvalidVaultReference)
+}
+
+func TestVaultProvider_PermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	server := newVaultStub(t)
+
+	provider := secrets.NewVaultProvider(secrets.VaultConfig{Address: server.URL, Token: "wrong"})
+	require.NotNil(t, provider)
+
+	_, err := provider.Secret(context.Background(), "secret/zadara/tokens#london")
+	require.ErrorIs(t, err, secrets.ErrVaultResponse)
+	assert.ErrorContains(t, err, "permission denied")
+}
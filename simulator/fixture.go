@@ -0,0 +1,110 @@
+package simulator
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Fixture represents the Command Center served by the simulator.
+	Fixture struct {
+		// Latency is added to every response.
+		Latency time.Duration `yaml:"latency"`
+
+		// ErrorRate is the ratio of requests, between 0 and 1, that fail with an internal server error.
+		ErrorRate float64 `yaml:"error_rate"`
+
+		// Token is the X-Token requests must have, unless the cloud sets its own. Any token is accepted if empty.
+		Token string `yaml:"token"`
+
+		// Clouds maps the cloud names to their object stores.
+		Clouds map[string]*Cloud `yaml:"clouds"`
+	}
+
+	// Cloud represents a cloud of the Command Center.
+	Cloud struct {
+		// Token overrides the fixture's token for the cloud.
+		Token string `yaml:"token"`
+
+		Stores []*Store `yaml:"stores"`
+	}
+
+	// Store represents an object store, with the fields of the Command Center API.
+	Store struct {
+		vpsaobjectstorage.Zios
+
+		Policies []*Policy `json:"policies"`
+	}
+
+	// Policy represents a storage policy, with the fields of the Command Center API.
+	Policy struct {
+		vpsaobjectstorage.ZiosStoragePolicy
+
+		// GrowthPerDay is how many bytes of free capacity become used each day the simulator runs.
+		GrowthPerDay int64 `json:"growth_per_day"`
+	}
+
+	// storeFields decodes the store without its UnmarshalYAML method.
+	storeFields Store
+)
+
+//go:embed default.yaml
+var defaultFixture []byte
+
+// UnmarshalYAML decodes the store using the JSON field names of the Command Center API,
+// so fixtures can be written from real responses.
+func (s *Store) UnmarshalYAML(node *yaml.Node) error {
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return fmt.Errorf("error decoding store: %w", err)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error decoding store: %w", err)
+	}
+
+	if err := json.Unmarshal(b, (*storeFields)(s)); err != nil {
+		return fmt.Errorf("error decoding store: %w", err)
+	}
+
+	return nil
+}
+
+// DecodeFixture decodes a YAML fixture from the reader.
+func DecodeFixture(r io.Reader) (*Fixture, error) {
+	var fixture Fixture
+	if err := yaml.NewDecoder(r).Decode(&fixture); err != nil {
+		return nil, fmt.Errorf("error decoding fixture: %w", err)
+	}
+
+	return &fixture, nil
+}
+
+// LoadFixture loads a YAML fixture from the file.
+func LoadFixture(path string) (*Fixture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening fixture: %w", err)
+	}
+	defer f.Close()
+
+	return DecodeFixture(f)
+}
+
+// DefaultFixture returns the built in fixture, a single cloud with two object stores.
+func DefaultFixture() *Fixture {
+	var fixture Fixture
+	if err := yaml.Unmarshal(defaultFixture, &fixture); err != nil {
+		panic(fmt.Sprintf("invalid default fixture: %v", err))
+	}
+
+	return &fixture
+}
@@ -0,0 +1,220 @@
+// Package simulator serves a fake Zadara Command Center API from a fixture,
+// for developing and testing the exporter offline.
+package simulator
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+)
+
+type (
+	// Server is an HTTP handler serving the Command Center API endpoints used by the exporter.
+	Server struct {
+		fixture *Fixture
+		mux     *http.ServeMux
+		start   time.Time
+		now     func() time.Time
+	}
+
+	// Option configures the Server.
+	Option func(*Server)
+
+	// errorResponse represents an error response of the Command Center API.
+	errorResponse struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+)
+
+const (
+	statusSuccess = "success"
+	statusError   = "error"
+
+	hoursPerDay = 24
+)
+
+// WithClock sets the function returning the current time, used to evolve the capacity of the policies.
+// The capacity evolves from the time the server is created.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// New creates a new simulator serving the fixture.
+func New(fixture *Fixture, opts ...Option) *Server {
+	s := &Server{
+		fixture: fixture,
+		mux:     http.NewServeMux(),
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.start = s.now()
+
+	s.mux.HandleFunc("GET /api/clouds.json", s.handleClouds)
+	s.mux.HandleFunc("GET /api/clouds/{cloud}/zioses.json", s.handleStores)
+	s.mux.HandleFunc("GET /api/clouds/{cloud}/zioses/{zios}/storage_policies.json", s.handleStoragePolicies)
+
+	return s
+}
+
+// ServeHTTP simulates the latency and errors of the fixture, checks the token and serves the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("simulator request", "method", r.Method, "path", r.URL.Path)
+
+	if s.fixture.Latency > 0 {
+		select {
+		case <-time.After(s.fixture.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if s.fixture.ErrorRate > 0 && rand.Float64() < s.fixture.ErrorRate { //nolint:gosec // not used for security
+		writeError(w, http.StatusInternalServerError, "Simulated error")
+
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// cloud returns the cloud of the request, writing an error response if it is unknown or the token is wrong.
+func (s *Server) cloud(w http.ResponseWriter, r *http.Request) (*Cloud, bool) {
+	cloud, ok := s.fixture.Clouds[r.PathValue("cloud")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Cloud not found")
+
+		return nil, false
+	}
+
+	token := s.fixture.Token
+	if cloud.Token != "" {
+		token = cloud.Token
+	}
+
+	if token != "" && r.Header.Get("X-Token") != token {
+		writeError(w, http.StatusUnauthorized, "Invalid token")
+
+		return nil, false
+	}
+
+	return cloud, true
+}
+
+func (s *Server) handleClouds(w http.ResponseWriter, r *http.Request) {
+	if s.fixture.Token != "" && r.Header.Get("X-Token") != s.fixture.Token {
+		writeError(w, http.StatusUnauthorized, "Invalid token")
+
+		return
+	}
+
+	names := make([]string, 0, len(s.fixture.Clouds))
+	for name := range s.fixture.Clouds {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	clouds := make([]*commandcenter.Cloud, 0, len(names))
+	for i, name := range names {
+		clouds = append(clouds, &commandcenter.Cloud{ID: i + 1, Name: name, Status: "normal"})
+	}
+
+	writeJSON(w, http.StatusOK, &commandcenter.CloudsResponse{
+		Status: statusSuccess,
+		Clouds: clouds,
+		Count:  len(clouds),
+	})
+}
+
+func (s *Server) handleStores(w http.ResponseWriter, r *http.Request) {
+	cloud, ok := s.cloud(w, r)
+	if !ok {
+		return
+	}
+
+	zioses := make([]*vpsaobjectstorage.Zios, 0, len(cloud.Stores))
+	for _, store := range cloud.Stores {
+		zios := store.Zios
+		zios.StoragePoliciesCount = int64(len(store.Policies))
+		zioses = append(zioses, &zios)
+	}
+
+	writeJSON(w, http.StatusOK, &vpsaobjectstorage.ZiosResponse{
+		Status: statusSuccess,
+		Zioses: zioses,
+		Count:  len(zioses),
+	})
+}
+
+func (s *Server) handleStoragePolicies(w http.ResponseWriter, r *http.Request) {
+	cloud, ok := s.cloud(w, r)
+	if !ok {
+		return
+	}
+
+	// Stores can be requested by ID or internal name.
+	id := r.PathValue("zios")
+
+	for _, store := range cloud.Stores {
+		if strconv.Itoa(store.ID) != id && store.InternalName != id {
+			continue
+		}
+
+		policies := make([]*vpsaobjectstorage.ZiosStoragePolicy, 0, len(store.Policies))
+		for _, policy := range store.Policies {
+			policies = append(policies, s.evolve(policy))
+		}
+
+		writeJSON(w, http.StatusOK, &vpsaobjectstorage.ZiosStoragePoliciesResponse{
+			Status:              statusSuccess,
+			ZiosStoragePolicies: policies,
+			Count:               len(policies),
+		})
+
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "Object storage not found")
+}
+
+// evolve returns the policy with its capacity grown by the time since the server was created.
+// The capacity stops growing once the policy is full, or shrinking once it is empty.
+func (s *Server) evolve(policy *Policy) *vpsaobjectstorage.ZiosStoragePolicy {
+	p := policy.ZiosStoragePolicy
+
+	days := s.now().Sub(s.start).Hours() / hoursPerDay
+	growth := min(int64(float64(policy.GrowthPerDay)*days), p.FreeCapacity)
+	growth = max(growth, -p.UsedCapacity)
+
+	p.UsedCapacity += growth
+	p.FreeCapacity -= growth
+
+	return &p
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("error writing simulator response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, &errorResponse{Status: statusError, Message: message})
+}
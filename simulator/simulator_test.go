@@ -0,0 +1,168 @@
+package simulator_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/simulator"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fixtureYAML = `
+token: secret
+clouds:
+  cc1:
+    stores:
+      - id: 7
+        name: store1
+        internal_name: zios-7
+        objects_count: 42
+        policies:
+          - id: 1
+            name: policy1
+            percentage_drives_added: "100"
+            used_capacity: 1000
+            free_capacity: 1500
+            growth_per_day: 1000
+            ring_balance:
+              degraded_percentage: 2.5
+  cc2:
+    token: other
+    stores: []
+`
+
+// clock is a clock that can be moved forward by the test.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newFixture(t *testing.T) *simulator.Fixture {
+	t.Helper()
+
+	fixture, err := simulator.DecodeFixture(strings.NewReader(fixtureYAML))
+	require.NoError(t, err)
+
+	return fixture
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	c := &clock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	server := httptest.NewServer(simulator.New(newFixture(t), simulator.WithClock(c.Now)))
+	defer server.Close()
+
+	client := commandcenter.NewClient(&config.Target{URL: server.URL, CloudName: "cc1", Token: "secret"})
+
+	stores, err := client.GetAllStoragePolicies(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stores, 1)
+
+	assert.Equal(t, 7, stores[0].Store.ID)
+	assert.Equal(t, "store1", stores[0].Store.Name)
+	assert.Equal(t, int64(42), stores[0].Store.ObjectsCount)
+	assert.Equal(t, int64(1), stores[0].Store.StoragePoliciesCount)
+
+	require.Len(t, stores[0].Policies, 1)
+	policy := stores[0].Policies[0]
+	assert.Equal(t, "policy1", policy.Name)
+	assert.InDelta(t, 2.5, policy.RingBalance.DegradedPercentage, 0)
+	assert.Equal(t, int64(1000), policy.UsedCapacity)
+	assert.Equal(t, int64(1500), policy.FreeCapacity)
+
+	// The capacity grows over time until the policy is full.
+	c.Add(12 * time.Hour)
+
+	res, err := client.GetStoragePolicies(context.Background(), "cc1", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), res.ZiosStoragePolicies[0].UsedCapacity)
+	assert.Equal(t, int64(1000), res.ZiosStoragePolicies[0].FreeCapacity)
+
+	c.Add(7 * 24 * time.Hour)
+
+	res, err = client.GetStoragePolicies(context.Background(), "cc1", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2500), res.ZiosStoragePolicies[0].UsedCapacity)
+	assert.Equal(t, int64(0), res.ZiosStoragePolicies[0].FreeCapacity)
+}
+
+func TestServer_Errors(t *testing.T) {
+	t.Parallel()
+
+	fixture := newFixture(t)
+	server := httptest.NewServer(simulator.New(fixture))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		cloud   string
+		token   string
+		message string
+	}{
+		{name: "wrong token", cloud: "cc1", token: "wrong", message: "Invalid token"},
+		{name: "cloud token", cloud: "cc2", token: "secret", message: "Invalid token"},
+		{name: "unknown cloud", cloud: "cc3", token: "secret", message: "Cloud not found"},
+	}
+
+	for _, tt := range tests {
+		client := commandcenter.NewClient(&config.Target{URL: server.URL, CloudName: tt.cloud, Token: tt.token})
+
+		_, err := client.GetStores(context.Background(), tt.cloud)
+		require.ErrorIs(t, err, vpsaobjectstorage.ErrResponse, tt.name)
+		assert.ErrorContains(t, err, tt.message, tt.name)
+	}
+
+	client := commandcenter.NewClient(&config.Target{URL: server.URL, CloudName: "cc1", Token: "secret"})
+
+	_, err := client.GetStoragePolicies(context.Background(), "cc1", 8)
+	require.ErrorContains(t, err, "Object storage not found")
+}
+
+func TestServer_ErrorRate(t *testing.T) {
+	t.Parallel()
+
+	fixture := newFixture(t)
+	fixture.ErrorRate = 1
+	fixture.Latency = time.Millisecond
+
+	server := httptest.NewServer(simulator.New(fixture))
+	defer server.Close()
+
+	client := commandcenter.NewClient(&config.Target{URL: server.URL, CloudName: "cc1", Token: "secret"})
+
+	_, err := client.GetStores(context.Background(), "cc1")
+	require.ErrorContains(t, err, "Simulated error")
+}
+
+func TestDefaultFixture(t *testing.T) {
+	t.Parallel()
+
+	fixture := simulator.DefaultFixture()
+
+	require.Contains(t, fixture.Clouds, "cc1")
+	assert.Len(t, fixture.Clouds["cc1"].Stores, 2)
+	assert.NotEmpty(t, fixture.Clouds["cc1"].Stores[0].Policies)
+}
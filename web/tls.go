@@ -0,0 +1,166 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// TLSServerConfig represents the TLS configuration of the metrics server.
+	TLSServerConfig struct {
+		CertFile string `mapstructure:"cert_file"`
+		KeyFile  string `mapstructure:"key_file"`
+
+		// ClientCAFile enables mutual TLS, verifying client certificates against the CAs in the file.
+		ClientCAFile string `mapstructure:"client_ca_file"`
+
+		// ClientAuthType is the policy for client certificates, named after the tls.ClientAuthType values.
+		// It defaults to RequireAndVerifyClientCert when a client CA file is set, and NoClientCert otherwise.
+		ClientAuthType string `mapstructure:"client_auth_type"`
+	}
+
+	// reloader reloads the TLS configuration when the certificate, key or client CA files change.
+	reloader struct {
+		config *TLSServerConfig
+
+		mu      sync.Mutex
+		modTime time.Time
+		current *tls.Config
+	}
+)
+
+var (
+	// ErrMissingCertificate is returned when the certificate or key file is not set.
+	ErrMissingCertificate = errors.New("cert_file and key_file must both be set")
+
+	// ErrUnknownClientAuthType is returned when the client auth type is not supported.
+	ErrUnknownClientAuthType = errors.New("unknown client_auth_type")
+
+	// ErrInvalidClientCA is returned when the client CA file does not contain any certificates.
+	ErrInvalidClientCA = errors.New("no certificates found in client CA file")
+)
+
+//nolint:gochecknoglobals // lookup table for the configuration
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// load reads the certificates and builds the TLS configuration.
+func (c *TLSServerConfig) load() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, ErrMissingCertificate
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.NoClientCert,
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client CA file: %w", err)
+		}
+
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidClientCA, c.ClientCAFile)
+		}
+
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if c.ClientAuthType != "" {
+		authType, ok := clientAuthTypes[c.ClientAuthType]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownClientAuthType, c.ClientAuthType)
+		}
+
+		config.ClientAuth = authType
+	}
+
+	return config, nil
+}
+
+// modTime returns the latest modification time of the certificate, key and client CA files.
+func (c *TLSServerConfig) modTime() time.Time {
+	var latest time.Time
+
+	for _, file := range []string{c.CertFile, c.KeyFile, c.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest
+}
+
+// tlsConfig returns a TLS configuration that reloads the certificates when the files change.
+func (c *TLSServerConfig) tlsConfig() *tls.Config {
+	r := &reloader{config: c}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.get,
+	}
+}
+
+// get returns the current TLS configuration, reloading it first if any of the files have changed.
+// If reloading fails, the previous configuration is kept.
+func (r *reloader) get(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime := r.config.modTime()
+	if r.current != nil && !modTime.After(r.modTime) {
+		return r.current, nil
+	}
+
+	config, err := r.config.load()
+	if err != nil {
+		if r.current == nil {
+			return nil, err
+		}
+
+		slog.Error("error reloading TLS configuration, keeping the previous one", "error", err)
+
+		// Wait for the files to change again before retrying.
+		r.modTime = modTime
+
+		return r.current, nil
+	}
+
+	if r.current != nil {
+		slog.Info("reloaded TLS configuration")
+	}
+
+	r.current = config
+	r.modTime = modTime
+
+	return config, nil
+}
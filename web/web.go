@@ -0,0 +1,133 @@
+// Package web secures the exporter's HTTP server with TLS and basic authentication,
+// configured in the style of the Prometheus exporter-toolkit web configuration.
+package web
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	// Config represents the web configuration of the metrics server.
+	Config struct {
+		// TLSServerConfig enables TLS when set.
+		TLSServerConfig *TLSServerConfig `mapstructure:"tls_server_config"`
+
+		// BasicAuthUsers maps usernames to bcrypt hashed passwords.
+		// Basic authentication is required when any users are set.
+		BasicAuthUsers map[string]string `mapstructure:"basic_auth_users"`
+	}
+
+	// basicAuth is an HTTP handler that requires basic authentication before calling the next handler.
+	basicAuth struct {
+		users map[string]string
+		next  http.Handler
+
+		// authenticated caches the successful authentications, as comparing bcrypt hashes is deliberately slow.
+		mu            sync.Mutex
+		authenticated map[[sha256.Size]byte]struct{}
+	}
+)
+
+// dummyHash is compared against for unknown users, so they take as long to reject as known users.
+//
+//nolint:gosec // not a credential
+const dummyHash = "$2a$10$2Vj1SuB02DP.3rTqVp5VeeJe4d0PjTG9r676Zf/fT64s9Ob0vgBJa"
+
+// ErrInvalidHash is returned when a basic authentication password is not a bcrypt hash.
+var ErrInvalidHash = errors.New("password is not a bcrypt hash")
+
+// Validate checks the configuration, returning an error if the certificates or password hashes are invalid.
+func (c Config) Validate() error {
+	for user, hash := range c.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("%w: user %s", ErrInvalidHash, user)
+		}
+	}
+
+	if c.TLSServerConfig != nil {
+		if _, err := c.TLSServerConfig.load(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler wraps the handler with basic authentication if any users are configured.
+func (c Config) Handler(next http.Handler) http.Handler {
+	if len(c.BasicAuthUsers) == 0 {
+		return next
+	}
+
+	return &basicAuth{
+		users:         c.BasicAuthUsers,
+		next:          next,
+		authenticated: make(map[[sha256.Size]byte]struct{}),
+	}
+}
+
+// ListenAndServe listens on the server's address and serves its handler, wrapped in basic authentication,
+// over TLS when it is configured.
+func ListenAndServe(server *http.Server, config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	server.Handler = config.Handler(server.Handler)
+
+	if config.TLSServerConfig == nil {
+		return server.ListenAndServe() //nolint:wrapcheck // returned as is so http.ErrServerClosed can be compared
+	}
+
+	server.TLSConfig = config.TLSServerConfig.tlsConfig()
+
+	// The certificates are provided by the TLS configuration so they are reloaded when changed.
+	return server.ListenAndServeTLS("", "") //nolint:wrapcheck // as above
+}
+
+// ServeHTTP checks the basic authentication credentials before calling the next handler.
+func (b *basicAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if ok && b.authenticate(user, pass) {
+		b.next.ServeHTTP(w, r)
+
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="zadara-exporter", charset="UTF-8"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func (b *basicAuth) authenticate(user, pass string) bool {
+	hash, known := b.users[user]
+	if !known {
+		hash = dummyHash
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + pass + "\x00" + hash))
+
+	b.mu.Lock()
+	_, cached := b.authenticated[key]
+	b.mu.Unlock()
+
+	if cached {
+		return true
+	}
+
+	valid := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+
+	// Only known users are cached, which bounds the cache to one entry per user and password.
+	if valid && known {
+		b.mu.Lock()
+		b.authenticated[key] = struct{}{}
+		b.mu.Unlock()
+	}
+
+	return valid && known
+}
@@ -0,0 +1,260 @@
+package web_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newCertificate creates a certificate for localhost, signed by the parent or self-signed if it is nil.
+func newCertificate(t *testing.T, parent *certificate, serial int64) *certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &certificate{cert: cert, key: key}
+}
+
+func (c *certificate) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func (c *certificate) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func hash(t *testing.T, password string) string {
+	t.Helper()
+
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return string(h)
+}
+
+func TestConfig_Handler(t *testing.T) {
+	t.Parallel()
+
+	config := web.Config{BasicAuthUsers: map[string]string{"prometheus": hash(t, "secret")}}
+	handler := config.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		noAuth   bool
+		want     int
+	}{
+		{name: "valid", user: "prometheus", password: "secret", want: http.StatusOK},
+		{name: "cached", user: "prometheus", password: "secret", want: http.StatusOK},
+		{name: "wrong password", user: "prometheus", password: "wrong", want: http.StatusUnauthorized},
+		{name: "unknown user", user: "unknown", password: "secret", want: http.StatusUnauthorized},
+		{name: "no credentials", noAuth: true, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if !tt.noAuth {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.want, rec.Code, tt.name)
+
+		if tt.want == http.StatusUnauthorized {
+			assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"), tt.name)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	err := web.Config{BasicAuthUsers: map[string]string{"prometheus": "plaintext"}}.Validate()
+	require.ErrorIs(t, err, web.ErrInvalidHash)
+
+	err = web.Config{TLSServerConfig: &web.TLSServerConfig{CertFile: "cert.pem"}}.Validate()
+	require.ErrorIs(t, err, web.ErrMissingCertificate)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	newCertificate(t, nil, 1).write(t, certFile, keyFile)
+
+	err = web.Config{TLSServerConfig: &web.TLSServerConfig{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ClientAuthType: "Sometimes",
+	}}.Validate()
+	require.ErrorIs(t, err, web.ErrUnknownClientAuthType)
+
+	require.NoError(t, web.Config{}.Validate())
+}
+
+// serve starts the server with the configuration on a random local port, returning its URL.
+func serve(t *testing.T, config web.Config) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	server := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+
+	go func() {
+		_ = web.ListenAndServe(server, config)
+	}()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+
+		_ = conn.Close()
+
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	return "https://" + addr
+}
+
+func client(roots *x509.CertPool, certs ...tls.Certificate) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				RootCAs:      roots,
+				Certificates: certs,
+			},
+			DisableKeepAlives: true,
+		},
+	}
+}
+
+func get(t *testing.T, c *http.Client, url string) (*x509.Certificate, error) {
+	t.Helper()
+
+	resp, err := c.Get(url) //nolint:noctx // test request
+	if err != nil {
+		return nil, err //nolint:wrapcheck // compared in the test
+	}
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	return resp.TLS.PeerCertificates[0], nil
+}
+
+func TestListenAndServe_MutualTLS(t *testing.T) {
+	t.Parallel()
+
+	ca := newCertificate(t, nil, 1)
+	serverCert := newCertificate(t, ca, 2)
+	clientCert := newCertificate(t, ca, 3)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+
+	serverCert.write(t, certFile, keyFile)
+	ca.write(t, caFile, filepath.Join(dir, "ca-key.pem"))
+
+	url := serve(t, web.Config{TLSServerConfig: &web.TLSServerConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+	}})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	_, err := get(t, client(roots), url)
+	require.Error(t, err, "clients without a certificate are rejected")
+
+	peer, err := get(t, client(roots, clientCert.tls()), url)
+	require.NoError(t, err)
+	assert.Equal(t, serverCert.cert.SerialNumber, peer.SerialNumber)
+
+	// Replace the certificate, which is picked up by the next connection.
+	renewed := newCertificate(t, ca, 4)
+	renewed.write(t, certFile, keyFile)
+
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	peer, err = get(t, client(roots, clientCert.tls()), url)
+	require.NoError(t, err)
+	assert.Equal(t, renewed.cert.SerialNumber, peer.SerialNumber)
+}
@@ -0,0 +1,195 @@
+package commandcenter
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	// BreakerState is the state of a circuit breaker.
+	BreakerState int
+
+	// BreakerConfig configures the circuit breaker of each Command Center.
+	BreakerConfig struct {
+		// FailureThreshold is the number of consecutive failed requests that opens the circuit.
+		// The circuit breaker is disabled if zero.
+		FailureThreshold int `mapstructure:"failure_threshold"`
+
+		// OpenTimeout is how long the circuit stays open before requests are let through again.
+		OpenTimeout time.Duration `mapstructure:"open_timeout"`
+
+		// HalfOpenRequests is the number of requests let through while half-open,
+		// which all have to succeed to close the circuit. It defaults to 1.
+		HalfOpenRequests int `mapstructure:"half_open_requests"`
+	}
+
+	// Breaker is a circuit breaker, which stops sending requests to a Command Center after consecutive failures.
+	// Requests are rejected while the circuit is open. After the open timeout the circuit is half-open,
+	// and a few requests are let through to decide whether to close the circuit again or reopen it.
+	// A nil Breaker lets all requests through.
+	Breaker struct {
+		config BreakerConfig
+		now    func() time.Time
+
+		mu        sync.Mutex
+		state     BreakerState
+		failures  int
+		openedAt  time.Time
+		probes    int
+		successes int
+	}
+)
+
+// The states of a circuit breaker.
+const (
+	// BreakerClosed lets requests through.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects requests.
+	BreakerOpen
+
+	// BreakerHalfOpen lets a limited number of requests through to test whether the Command Center has recovered.
+	BreakerHalfOpen
+)
+
+// DefaultBreakerConfig is the default circuit breaker configuration.
+//
+//nolint:gochecknoglobals // the default configuration
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+	HalfOpenRequests: 1,
+}
+
+// ErrCircuitOpen is returned when a request is rejected because the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// String returns the name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// NewBreaker returns a circuit breaker with the configuration, or nil if it is disabled.
+func NewBreaker(config BreakerConfig) *Breaker {
+	if config.FailureThreshold <= 0 {
+		return nil
+	}
+
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+
+	return &Breaker{config: config, now: time.Now}
+}
+
+// State returns the state of the circuit breaker.
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Allow returns ErrCircuitOpen if the request is rejected. Requests that are let through have to be
+// recorded with Record.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			return ErrCircuitOpen
+		}
+
+		b.state = BreakerHalfOpen
+		b.probes = 0
+		b.successes = 0
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probes >= b.config.HalfOpenRequests {
+			return ErrCircuitOpen
+		}
+
+		b.probes++
+	}
+
+	return nil
+}
+
+// Record records whether a request that was let through succeeded.
+func (b *Breaker) Record(success bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		if success {
+			b.failures = 0
+
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.open()
+		}
+	case BreakerHalfOpen:
+		if !success {
+			b.open()
+
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			b.state = BreakerClosed
+			b.failures = 0
+		}
+	case BreakerOpen:
+		// The request was let through before the circuit opened.
+	}
+}
+
+// Cancel records that a request that was let through was not sent, or was cancelled by the caller,
+// so it neither succeeded nor failed.
+func (b *Breaker) Cancel() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// open opens the circuit. The lock must be held.
+func (b *Breaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+}
@@ -0,0 +1,98 @@
+package commandcenter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	const openTimeout = 20 * time.Millisecond
+
+	breaker := commandcenter.NewBreaker(commandcenter.BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      openTimeout,
+		HalfOpenRequests: 2,
+	})
+
+	// A success resets the consecutive failures.
+	for _, success := range []bool{false, true, false} {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(success)
+	}
+
+	assert.Equal(t, commandcenter.BreakerClosed, breaker.State())
+
+	require.NoError(t, breaker.Allow())
+	breaker.Record(false)
+	assert.Equal(t, commandcenter.BreakerOpen, breaker.State())
+	require.ErrorIs(t, breaker.Allow(), commandcenter.ErrCircuitOpen)
+
+	// Only the half-open requests are let through after the open timeout, and a failure reopens the circuit.
+	time.Sleep(openTimeout)
+
+	require.NoError(t, breaker.Allow())
+	require.NoError(t, breaker.Allow())
+	assert.Equal(t, commandcenter.BreakerHalfOpen, breaker.State())
+	require.ErrorIs(t, breaker.Allow(), commandcenter.ErrCircuitOpen)
+
+	breaker.Record(true)
+	breaker.Record(false)
+	assert.Equal(t, commandcenter.BreakerOpen, breaker.State())
+
+	// The circuit closes when all the half-open requests succeed.
+	time.Sleep(openTimeout)
+
+	require.NoError(t, breaker.Allow())
+	require.NoError(t, breaker.Allow())
+	breaker.Record(true)
+	assert.Equal(t, commandcenter.BreakerHalfOpen, breaker.State())
+	breaker.Record(true)
+	assert.Equal(t, commandcenter.BreakerClosed, breaker.State())
+}
+
+func TestBreakerCancel(t *testing.T) {
+	t.Parallel()
+
+	breaker := commandcenter.NewBreaker(commandcenter.BreakerConfig{FailureThreshold: 1})
+
+	require.NoError(t, breaker.Allow())
+	breaker.Record(false)
+
+	// The open timeout is zero, so the circuit is half-open straight away.
+	require.NoError(t, breaker.Allow())
+	require.ErrorIs(t, breaker.Allow(), commandcenter.ErrCircuitOpen)
+
+	// A cancelled request lets another request through.
+	breaker.Cancel()
+	require.NoError(t, breaker.Allow())
+	breaker.Record(true)
+	assert.Equal(t, commandcenter.BreakerClosed, breaker.State())
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	t.Parallel()
+
+	breaker := commandcenter.NewBreaker(commandcenter.BreakerConfig{})
+	assert.Nil(t, breaker)
+
+	for range 10 {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(false)
+	}
+
+	assert.Equal(t, commandcenter.BreakerClosed, breaker.State())
+}
+
+func TestBreakerStateString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "closed", commandcenter.BreakerClosed.String())
+	assert.Equal(t, "open", commandcenter.BreakerOpen.String())
+	assert.Equal(t, "half_open", commandcenter.BreakerHalfOpen.String())
+}
@@ -0,0 +1,101 @@
+package commandcenter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/internal/api"
+)
+
+type (
+	// Cloud represents a cloud managed by the Command Center.
+	Cloud struct {
+		ID     int    `json:"id"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	}
+
+	// CloudsResponse represents the response of the GetClouds API.
+	CloudsResponse struct {
+		Status  string   `json:"status"`
+		Message string   `json:"message"`
+		Clouds  []*Cloud `json:"clouds"`
+		Count   int      `json:"count"`
+	}
+)
+
+// ErrInvalidCloudPattern is returned when an include or exclude pattern is not a valid regular expression.
+var ErrInvalidCloudPattern = errors.New("invalid cloud name pattern")
+
+// GetClouds retrieves the clouds managed by the Command Center.
+//
+// # API Docs
+//
+// Returns a list of all clouds.
+// GET /api/clouds(.xml/json)
+//
+// Example:
+// curl -X GET -H "Content-Type: application/json" -H "X-Token: <token>" \
+// 'https://<command-center-ip>:8888/api/clouds.json'.
+func (c *Client) GetClouds(ctx context.Context) (*CloudsResponse, error) {
+	return api.Get[CloudsResponse](ctx, c.C, c.BaseURL, api.Endpoint{Path: []string{"api", "clouds"}})
+}
+
+// matchClouds compiles the patterns, anchored to match the whole cloud name.
+func matchClouds(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidCloudPattern, pattern, err)
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// CloudTargets returns the target of each cloud of the Command Center included by the target's discovery
+// configuration, or the target itself if its clouds are not discovered.
+func (c *Client) CloudTargets(ctx context.Context, target *config.Target) ([]*config.Target, error) {
+	if !target.DiscoverClouds.Enabled {
+		return []*config.Target{target}, nil
+	}
+
+	include, err := matchClouds(target.DiscoverClouds.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := matchClouds(target.DiscoverClouds.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.GetClouds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting clouds: %w", err)
+	}
+
+	targets := make([]*config.Target, 0, len(res.Clouds))
+
+	for _, cloud := range res.Clouds {
+		matches := func(re *regexp.Regexp) bool {
+			return re.MatchString(cloud.Name)
+		}
+
+		if (len(include) > 0 && !slices.ContainsFunc(include, matches)) || slices.ContainsFunc(exclude, matches) {
+			continue
+		}
+
+		targets = append(targets, target.ForCloud(cloud.Name))
+	}
+
+	return targets, nil
+}
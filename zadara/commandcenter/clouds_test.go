@@ -0,0 +1,198 @@
+package commandcenter_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cloudsResponse = `{
+	"status": "success",
+	"clouds": [
+		{"id": 1, "name": "cc1", "status": "normal"},
+		{"id": 2, "name": "cc2", "status": "normal"},
+		{"id": 3, "name": "cc10", "status": "normal"},
+		{"id": 4, "name": "lab", "status": "normal"}
+	],
+	"count": 4
+}`
+
+func newCloudsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/clouds.json", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+
+		_, _ = w.Write([]byte(cloudsResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestGetClouds(t *testing.T) {
+	t.Parallel()
+
+	server := newCloudsServer(t)
+	client := commandcenter.NewClient(&config.Target{URL: server.URL, Token: "secret"})
+
+	res, err := client.GetClouds(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "success", res.Status)
+	assert.Equal(t, 4, res.Count)
+	require.Len(t, res.Clouds, 4)
+	assert.Equal(t, &commandcenter.Cloud{ID: 1, Name: "cc1", Status: "normal"}, res.Clouds[0])
+}
+
+func TestCloudTargets(t *testing.T) {
+	t.Parallel()
+
+	server := newCloudsServer(t)
+
+	tests := []struct {
+		name      string
+		discovery config.CloudDiscovery
+		want      []string
+	}{
+		{
+			name: "disabled",
+			want: []string{"cc0"},
+		},
+		{
+			name:      "all clouds",
+			discovery: config.CloudDiscovery{Enabled: true},
+			want:      []string{"cc1", "cc2", "cc10", "lab"},
+		},
+		{
+			name:      "include",
+			discovery: config.CloudDiscovery{Enabled: true, Include: []string{"cc\\d"}},
+			want:      []string{"cc1", "cc2"},
+		},
+		{
+			name:      "include and exclude",
+			discovery: config.CloudDiscovery{Enabled: true, Include: []string{"cc.*"}, Exclude: []string{"cc2", "cc1.+"}},
+			want:      []string{"cc1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			target := &config.Target{
+				Name:           "London",
+				URL:            server.URL,
+				Token:          "secret",
+				CloudName:      "cc0",
+				Labels:         map[string]string{"region": "eu"},
+				DiscoverClouds: tt.discovery,
+			}
+
+			targets, err := commandcenter.NewClient(target).CloudTargets(context.Background(), target)
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(targets))
+			for _, cloudTarget := range targets {
+				assert.Equal(t, "London", cloudTarget.Name)
+				assert.Equal(t, map[string]string{"region": "eu"}, cloudTarget.Labels)
+				assert.False(t, cloudTarget.DiscoverClouds.Enabled)
+
+				names = append(names, cloudTarget.CloudName)
+			}
+
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestCloudTargets_InvalidPattern(t *testing.T) {
+	t.Parallel()
+
+	target := &config.Target{
+		Name:           "London",
+		URL:            "http://127.0.0.1:0",
+		DiscoverClouds: config.CloudDiscovery{Enabled: true, Exclude: []string{"cc("}},
+	}
+
+	_, err := commandcenter.NewClient(target).CloudTargets(context.Background(), target)
+	require.ErrorIs(t, err, commandcenter.ErrInvalidCloudPattern)
+}
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	var (
+		connections atomic.Int64
+		mu          sync.Mutex
+		tokens      []string
+	)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("X-Token"))
+		mu.Unlock()
+
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	pool := commandcenter.NewPool()
+	london := &config.Target{Name: "London", URL: server.URL, Token: "secret", CloudName: "cc1"}
+	paris := &config.Target{Name: "Paris", URL: server.URL, Token: "other", CloudName: "cc2"}
+
+	for _, target := range []*config.Target{london, london.ForCloud("cc3"), paris} {
+		client := pool.Client(target)
+		assert.Equal(t, target.CloudName, client.CloudName)
+
+		_, err := client.GetStores(context.Background(), target.CloudName)
+		require.NoError(t, err)
+	}
+
+	// The targets of the Command Center share its connections, but send their own tokens.
+	assert.Equal(t, int64(1), connections.Load())
+	assert.Equal(t, []string{"secret", "secret", "other"}, tokens)
+}
+
+func TestWithPageSize(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]string{
+		"1": `{"status": "success", "zioses": [{"id": 1, "name": "store1"}, {"id": 2, "name": "store2"}]}`,
+		"2": `{"status": "success", "zioses": [{"id": 3, "name": "store3"}]}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2", r.URL.Query().Get("per_page"))
+
+		_, _ = w.Write([]byte(pages[r.URL.Query().Get("page")]))
+	}))
+	defer server.Close()
+
+	target := &config.Target{Name: "London", URL: server.URL, CloudName: "cc1"}
+
+	for _, client := range []*commandcenter.Client{
+		commandcenter.NewClient(target, commandcenter.WithPageSize(2)),
+		commandcenter.NewPool(commandcenter.WithPageSize(2)).Client(target),
+	} {
+		res, err := client.GetStores(context.Background(), "cc1")
+		require.NoError(t, err)
+		assert.Equal(t, 3, res.Count)
+	}
+}
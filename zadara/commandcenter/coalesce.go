@@ -0,0 +1,263 @@
+package commandcenter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+)
+
+type (
+	// CoalesceConfig configures the coalescing of identical requests.
+	CoalesceConfig struct {
+		// Enabled is whether concurrent identical requests share one request and response.
+		Enabled bool `mapstructure:"enabled"`
+
+		// CacheTTL is how long successful responses are reused for. They are not cached if zero.
+		CacheTTL time.Duration `mapstructure:"cache_ttl"`
+
+		// Timeout is how long a shared request waits for the Command Center for requests without a deadline.
+		// (default: 30s)
+		Timeout time.Duration `mapstructure:"timeout"`
+	}
+
+	// Coalescer shares one in-flight request between concurrent identical GET requests to a target,
+	// and optionally reuses successful responses for a short time.
+	Coalescer struct {
+		cacheTTL time.Duration
+		timeout  time.Duration
+		now      func() time.Time
+
+		mu      sync.Mutex
+		cache   map[string]*sharedResponse
+		flights map[string]*flight
+	}
+
+	// flight is a request shared by concurrent identical requests. It is cancelled at the latest deadline of
+	// the requests waiting for it, so a Command Center that stops responding does not hold up later requests.
+	flight struct {
+		ctx    context.Context //nolint:containedctx // the context of the shared request
+		cancel context.CancelCauseFunc
+		timer  *time.Timer
+
+		// deadline is guarded by the mutex of the coalescer.
+		deadline time.Time
+
+		done   chan struct{}
+		shared *sharedResponse
+		err    error
+	}
+
+	// sharedResponse is a response whose body has been read, so it can be returned to several requests.
+	sharedResponse struct {
+		res     *http.Response
+		body    []byte
+		expires time.Time
+	}
+
+	// coalesceTransport coalesces the GET requests of a target.
+	coalesceTransport struct {
+		coalescer *Coalescer
+		target    string
+		next      http.RoundTripper
+	}
+)
+
+// DefaultCoalesceTimeout is the default time a shared request waits for requests without a deadline.
+const DefaultCoalesceTimeout = 30 * time.Second
+
+// NewCoalescer returns a coalescer with the configuration, caching successful responses for the TTL,
+// or not at all if zero.
+func NewCoalescer(cfg CoalesceConfig) *Coalescer {
+	c := &Coalescer{
+		cacheTTL: cfg.CacheTTL,
+		timeout:  cfg.Timeout,
+		now:      time.Now,
+		cache:    map[string]*sharedResponse{},
+		flights:  map[string]*flight{},
+	}
+
+	if c.timeout <= 0 {
+		c.timeout = DefaultCoalesceTimeout
+	}
+
+	return c
+}
+
+// transport returns a transport coalescing the requests of the target before sending them with the next transport.
+func (c *Coalescer) transport(target *config.Target, next http.RoundTripper) http.RoundTripper {
+	return &coalesceTransport{coalescer: c, target: target.Name, next: next}
+}
+
+// cached returns the cached response for the key, if it has not expired.
+func (c *Coalescer) cached(key string) (*sharedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	shared, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !c.now().Before(shared.expires) {
+		delete(c.cache, key)
+
+		return nil, false
+	}
+
+	return shared, true
+}
+
+// store caches the response for the key if it succeeded, removing the expired responses.
+func (c *Coalescer) store(key string, shared *sharedResponse) {
+	if c.cacheTTL <= 0 || shared.res.StatusCode != http.StatusOK {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, cached := range c.cache {
+		if !now.Before(cached.expires) {
+			delete(c.cache, k)
+		}
+	}
+
+	shared.expires = now.Add(c.cacheTTL)
+	c.cache[key] = shared
+}
+
+// deadline returns the deadline of the context, bounded by the timeout of the coalescer.
+func (c *Coalescer) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+
+	return deadline
+}
+
+// join returns the in-flight request for the key, extended to the deadline, and whether it has just been
+// started by the request so it must be sent.
+func (c *Coalescer) join(key string, req *http.Request) (*flight, bool) {
+	deadline := c.deadline(req.Context())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f, ok := c.flights[key]; ok {
+		if deadline.After(f.deadline) {
+			f.deadline = deadline
+			f.timer.Reset(time.Until(deadline))
+		}
+
+		return f, false
+	}
+
+	// The shared request is not cancelled with the request that started it, as other requests may be waiting for it.
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(req.Context()))
+
+	f := &flight{
+		ctx:      ctx,
+		cancel:   cancel,
+		timer:    time.AfterFunc(time.Until(deadline), func() { cancel(context.DeadlineExceeded) }),
+		deadline: deadline,
+		done:     make(chan struct{}),
+	}
+	c.flights[key] = f
+
+	return f, true
+}
+
+// finish caches the response of the in-flight request for the key and returns it to the requests waiting for it.
+// Later requests start a new request.
+func (c *Coalescer) finish(key string, f *flight, shared *sharedResponse, err error) {
+	if err == nil {
+		c.store(key, shared)
+	}
+
+	c.mu.Lock()
+	delete(c.flights, key)
+	c.mu.Unlock()
+
+	f.timer.Stop()
+	f.cancel(nil)
+
+	f.shared, f.err = shared, err
+	close(f.done)
+}
+
+// response returns a copy of the shared response for the request, with its own body.
+func (s *sharedResponse) response(req *http.Request) *http.Response {
+	res := *s.res
+	res.Header = s.res.Header.Clone()
+	res.Body = io.NopCloser(bytes.NewReader(s.body))
+	res.Request = req
+
+	return &res
+}
+
+// send sends the request shared by the flight, reading its response so it can be returned to several requests.
+func (t *coalesceTransport) send(key string, req *http.Request, f *flight) {
+	res, err := t.next.RoundTrip(req.WithContext(f.ctx))
+	if err != nil {
+		// The shared request is only cancelled before it finishes when it reaches its deadline.
+		if cause := context.Cause(f.ctx); cause != nil {
+			err = fmt.Errorf("%w: %w", cause, err)
+		}
+
+		t.coalescer.finish(key, f, nil, err)
+
+		return
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if closeErr := res.Body.Close(); closeErr != nil {
+		slog.Error("error closing response body", "error", closeErr)
+	}
+
+	if err != nil {
+		t.coalescer.finish(key, f, nil, fmt.Errorf("error reading response: %w", err))
+
+		return
+	}
+
+	t.coalescer.finish(key, f, &sharedResponse{res: res, body: body}, nil)
+}
+
+// RoundTrip sends the request, sharing the response with concurrent identical GET requests.
+// The shared request lasts until the latest deadline of the requests waiting for it.
+func (t *coalesceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req) //nolint:wrapcheck // the errors are wrapped by the next transport
+	}
+
+	key := t.target + " " + req.URL.String()
+
+	if shared, ok := t.coalescer.cached(key); ok {
+		return shared.response(req), nil
+	}
+
+	f, started := t.coalescer.join(key, req)
+	if started {
+		go t.send(key, req, f)
+	}
+
+	select {
+	case <-req.Context().Done():
+		return nil, fmt.Errorf("error waiting for response: %w", req.Context().Err())
+	case <-f.done:
+		if f.err != nil {
+			return nil, f.err //nolint:wrapcheck // the errors are wrapped by the next transport
+		}
+
+		return f.shared.response(req), nil
+	}
+}
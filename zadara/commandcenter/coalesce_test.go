@@ -0,0 +1,211 @@
+package commandcenter_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescerConcurrentRequests(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+
+		_, _ = w.Write([]byte(`{"status": "success", "zioses": [{"name": "store1"}], "count": 1}`))
+	}))
+	defer server.Close()
+
+	coalescer := commandcenter.NewCoalescer(commandcenter.CoalesceConfig{})
+	target := &config.Target{Name: "London", URL: server.URL, CloudName: "cc1"}
+
+	const callers = 5
+
+	var wg sync.WaitGroup
+
+	for range callers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			res, err := commandcenter.NewClient(target, commandcenter.WithCoalescer(coalescer)).
+				GetStores(context.Background(), "cc1")
+			assert.NoError(t, err)
+			assert.Equal(t, "store1", res.Zioses[0].Name)
+		}()
+	}
+
+	// Let all the callers join the in-flight request before it completes.
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), requests.Load())
+
+	// Without a cache, the next request is sent again.
+	_, err := commandcenter.NewClient(target, commandcenter.WithCoalescer(coalescer)).
+		GetStores(context.Background(), "cc1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), requests.Load())
+}
+
+func TestCoalescerCache(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.URL.Path == "/api/clouds/broken/zioses.json" {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	coalescer := commandcenter.NewCoalescer(commandcenter.CoalesceConfig{CacheTTL: time.Hour})
+	london := commandcenter.NewClient(&config.Target{Name: "London", URL: server.URL},
+		commandcenter.WithCoalescer(coalescer))
+	newYork := commandcenter.NewClient(&config.Target{Name: "New York", URL: server.URL},
+		commandcenter.WithCoalescer(coalescer))
+
+	for range 3 {
+		_, err := london.GetStores(context.Background(), "cc1")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(1), requests.Load())
+
+	// The responses are not shared between targets.
+	_, err := newYork.GetStores(context.Background(), "cc1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), requests.Load())
+
+	// Error responses are not cached.
+	for range 2 {
+		_, err := london.GetStores(context.Background(), "broken")
+		require.Error(t, err)
+	}
+
+	assert.Equal(t, int64(4), requests.Load())
+}
+
+func TestCoalescerHangingServer(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+
+	cancelled := make(chan struct{})
+
+	// The first request hangs until it is cancelled, the later ones succeed.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			<-r.Context().Done()
+			close(cancelled)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	client := commandcenter.NewClient(&config.Target{Name: "London", URL: server.URL},
+		commandcenter.WithCoalescer(commandcenter.NewCoalescer(commandcenter.CoalesceConfig{})))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := client.GetStores(ctx, "cc1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The shared request is cancelled at the deadline of the request waiting for it.
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the shared request was not cancelled")
+	}
+
+	// The later requests are not held up by the hanging request.
+	require.Eventually(t, func() bool {
+		_, err := client.GetStores(context.Background(), "cc1")
+
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCoalescerLatestDeadline(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		time.Sleep(200 * time.Millisecond)
+
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	coalescer := commandcenter.NewCoalescer(commandcenter.CoalesceConfig{})
+	client := commandcenter.NewClient(&config.Target{Name: "London", URL: server.URL},
+		commandcenter.WithCoalescer(coalescer))
+
+	shortCtx, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+
+	shortErr := make(chan error)
+
+	go func() {
+		_, err := client.GetStores(shortCtx, "cc1")
+		shortErr <- err
+	}()
+
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	// The request joining the shared request extends it to its later deadline.
+	longCtx, cancelLong := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelLong()
+
+	_, err := client.GetStores(longCtx, "cc1")
+	require.NoError(t, err)
+	require.ErrorIs(t, <-shortErr, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), requests.Load())
+}
+
+func TestCoalescerTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	coalescer := commandcenter.NewCoalescer(commandcenter.CoalesceConfig{Timeout: 100 * time.Millisecond})
+	client := commandcenter.NewClient(&config.Target{Name: "London", URL: server.URL},
+		commandcenter.WithCoalescer(coalescer))
+
+	// Requests without a deadline are bounded by the timeout.
+	_, err := client.GetStores(context.Background(), "cc1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -4,6 +4,7 @@ package commandcenter
 import (
 	"context"
 	"net/http"
+	"sync"
 
 	"github.com/krystal/zadara-exporter/config"
 	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
@@ -29,21 +30,145 @@ type (
 		CloudName string
 		C         *http.Client
 		VPSAObjectStorage
+
+		token  string
+		tokens TokenSource
+	}
+
+	// Option configures the Client.
+	Option func(*options)
+
+	// Pool creates the clients of the targets, sharing one transport, and so one pool of connections,
+	// per Command Center URL.
+	Pool struct {
+		opts []Option
+
+		mu         sync.Mutex
+		transports map[string]http.RoundTripper
+	}
+
+	// options represents the configurable options of the Client.
+	options struct {
+		transport http.RoundTripper
+		limiters  *Limiters
+		coalescer *Coalescer
+		tokens    TokenSource
+		pageSize  int
 	}
 )
 
-// NewClient creates a new instance of the Client struct.
-// It takes a pointer to a config.Target struct as a parameter and returns a pointer to the Client struct.
-// The Client struct contains the necessary information to interact with the Zadara Command Centre API.
-func NewClient(target *config.Target) *Client {
-	httpClient := &http.Client{
-		Transport: newAddTokenHeaderTransport(http.DefaultTransport, target.Token),
+// WithTransport sets the transport the client sends its requests with, defaulting to http.DefaultTransport.
+// The X-Token header is added to the requests before they are passed to the transport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithTokenSource resolves the tokens of the targets with the source on each request, such as tokens referencing
+// a secret manager, resolving them again when they are rejected.
+func WithTokenSource(source TokenSource) Option {
+	return func(o *options) {
+		o.tokens = source
 	}
+}
+
+// WithLimiters limits the requests of the client with the limiter of its target.
+func WithLimiters(limiters *Limiters) Option {
+	return func(o *options) {
+		o.limiters = limiters
+	}
+}
+
+// WithCoalescer shares the responses to concurrent identical GET requests of the clients of a target.
+func WithCoalescer(coalescer *Coalescer) Option {
+	return func(o *options) {
+		o.coalescer = coalescer
+	}
+}
+
+// WithPageSize requests the stores a page of the size at a time, instead of all at once.
+func WithPageSize(size int) Option {
+	return func(o *options) {
+		o.pageSize = size
+	}
+}
+
+// newOptions returns the options configured by opts.
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// newHTTPClient returns the HTTP client sending the requests of the target with the options.
+func newHTTPClient(target *config.Target, o options) *http.Client {
+	var transport http.RoundTripper = newAddTokenHeaderTransport(o.transport, target, o.tokens)
+	if o.limiters != nil {
+		transport = &limiterTransport{limiter: o.limiters.Limiter(target), next: transport}
+	}
+
+	// Coalesced requests are only sent, and limited, once.
+	if o.coalescer != nil {
+		transport = o.coalescer.transport(target, transport)
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+// newClient returns a client of the target sending its requests with the HTTP client.
+func newClient(target *config.Target, httpClient *http.Client, o options) *Client {
+	vpsaClient := vpsaobjectstorage.NewClient(target.URL, httpClient)
+	vpsaClient.PerPage = o.pageSize
 
 	return &Client{
 		BaseURL:           target.URL,
 		C:                 httpClient,
 		CloudName:         target.CloudName,
-		VPSAObjectStorage: vpsaobjectstorage.NewClient(target.URL, httpClient),
+		VPSAObjectStorage: vpsaClient,
+		token:             target.Token,
+		tokens:            o.tokens,
 	}
 }
+
+// NewClient creates a new instance of the Client struct.
+// It takes a pointer to a config.Target struct as a parameter and returns a pointer to the Client struct.
+// The Client struct contains the necessary information to interact with the Zadara Command Centre API.
+func NewClient(target *config.Target, opts ...Option) *Client {
+	o := newOptions(opts)
+
+	return newClient(target, newHTTPClient(target, o), o)
+}
+
+// NewPool returns a pool of clients with the options.
+func NewPool(opts ...Option) *Pool {
+	return &Pool{opts: opts, transports: map[string]http.RoundTripper{}}
+}
+
+// Client returns a client of the target. The clients of all the targets of a Command Center URL, including
+// those of their discovered clouds, send their requests with one transport, unless the options set a transport.
+// The token, limiter and coalescer of each target are applied on top of it.
+func (p *Pool) Client(target *config.Target) *Client {
+	o := newOptions(p.opts)
+
+	if o.transport == nil {
+		p.mu.Lock()
+
+		transport, ok := p.transports[target.URL]
+		if !ok {
+			transport = http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // always a transport
+			p.transports[target.URL] = transport
+		}
+
+		p.mu.Unlock()
+
+		o.transport = transport
+	}
+
+	return newClient(target, newHTTPClient(target, o), o)
+}
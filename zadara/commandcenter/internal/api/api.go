@@ -0,0 +1,261 @@
+// Package api provides the requests shared by the Zadara Command Centre API endpoints.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Format is the format of the responses, which is the extension of the endpoint path.
+	Format string
+
+	// Endpoint describes a request to a Command Center API endpoint.
+	Endpoint struct {
+		// Path is the segments of the endpoint path without the format extension, such as
+		// "api", "clouds", cloudName, "zioses". Each segment is escaped.
+		Path []string
+
+		// Query is the query parameters of the request.
+		Query url.Values
+
+		// Format is the format of the response, defaulting to FormatJSON.
+		Format Format
+
+		// MaxBodySize is the maximum size of the response body in bytes, defaulting to DefaultMaxBodySize.
+		MaxBodySize int64
+	}
+)
+
+// The formats of the responses.
+const (
+	FormatJSON Format = "json"
+	FormatXML  Format = "xml"
+)
+
+// DefaultMaxBodySize is the maximum size of a response body when the endpoint does not set one.
+const DefaultMaxBodySize = 32 << 20
+
+// The query parameters of paginated endpoints.
+const (
+	pageParam    = "page"
+	perPageParam = "per_page"
+)
+
+// MaxPages is the maximum number of pages requested from a paginated endpoint.
+const MaxPages = 1000
+
+var (
+	// ErrBodyTooLarge is returned when a response body is larger than the maximum size of the endpoint.
+	ErrBodyTooLarge = errors.New("response body too large")
+
+	// ErrTooManyPages is returned when a paginated endpoint has more than MaxPages pages.
+	ErrTooManyPages = errors.New("too many pages")
+
+	// ErrUnexpectedContentType is returned when a response is not in the format of the endpoint.
+	ErrUnexpectedContentType = errors.New("unexpected content type")
+)
+
+// format returns the format of the endpoint.
+func (e Endpoint) format() Format {
+	if e.Format == "" {
+		return FormatJSON
+	}
+
+	return e.Format
+}
+
+// URL returns the URL of the endpoint relative to the base URL of the Command Center API.
+func (e Endpoint) URL(baseURL string) (string, error) {
+	segments := make([]string, len(e.Path))
+	for i, segment := range e.Path {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/" + strings.Join(segments, "/") + "." + string(e.format()))
+	if err != nil {
+		return "", fmt.Errorf("error building URL: %w", err)
+	}
+
+	u.RawQuery = e.Query.Encode()
+
+	return u.String(), nil
+}
+
+// Get sends a GET request to the endpoint and decodes the response into a T.
+// It returns an APIError if the response has an HTTP error status or an error status in the body.
+func Get[T any](ctx context.Context, c *http.Client, baseURL string, endpoint Endpoint) (*T, error) {
+	endpointURL, err := endpoint.URL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	if endpoint.format() == FormatXML {
+		req.Header.Set("Accept", "application/xml")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error in sending request: %w", err)
+	}
+
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			slog.Error("error closing response body", "error", err)
+		}
+	}()
+
+	var resp T
+	if err := decodeResponse(res, endpoint, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// GetPages sends GET requests to a paginated endpoint for perPage items at a time, until a page has fewer
+// items, and returns the items of all the pages.
+// It also stops at an empty page, or at a page starting with the same item as the previous page, as the
+// endpoint then ignores the pagination parameters and returns all the items for every page.
+func GetPages[T, I any, K comparable](
+	ctx context.Context,
+	c *http.Client,
+	baseURL string,
+	endpoint Endpoint,
+	perPage int,
+	items func(*T) []I,
+	key func(I) K,
+) ([]I, error) {
+	var (
+		all   []I
+		first K
+	)
+
+	for page := 1; page <= MaxPages; page++ {
+		query := url.Values{}
+		for key, values := range endpoint.Query {
+			query[key] = values
+		}
+
+		query.Set(pageParam, strconv.Itoa(page))
+		query.Set(perPageParam, strconv.Itoa(perPage))
+
+		pageEndpoint := endpoint
+		pageEndpoint.Query = query
+
+		resp, err := Get[T](ctx, c, baseURL, pageEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("error getting page %d: %w", page, err)
+		}
+
+		pageItems := items(resp)
+		if len(pageItems) == 0 || (page > 1 && key(pageItems[0]) == first) {
+			return all, nil
+		}
+
+		all = append(all, pageItems...)
+		first = key(pageItems[0])
+
+		if len(pageItems) < perPage {
+			return all, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: more than %d", ErrTooManyPages, MaxPages)
+}
+
+// checkContentType returns an error if the response has a content type other than the format of the endpoint,
+// so a response in another format is not decoded into empty values. Responses without a content type or
+// with text/plain, as sent by servers that do not set one, are decoded in the format of the endpoint.
+func checkContentType(res *http.Response, endpoint Endpoint) error {
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnexpectedContentType, contentType)
+	}
+
+	if mediaType == "text/plain" || strings.HasSuffix(mediaType, string(endpoint.format())) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s, expected %s", ErrUnexpectedContentType, mediaType, endpoint.format())
+}
+
+// readBody reads the response body, up to the maximum size of the endpoint.
+func readBody(res *http.Response, endpoint Endpoint) ([]byte, error) {
+	maxSize := endpoint.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBodySize
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, maxSize)
+	}
+
+	return body, nil
+}
+
+// decodeResponse decodes the response into v. It returns an APIError if the response does not have a successful
+// 2xx HTTP status, whether or not the body can be decoded, or if it has an error status in the body.
+func decodeResponse(res *http.Response, endpoint Endpoint, v any) error {
+	body, err := readBody(res, endpoint)
+	if err != nil {
+		return err
+	}
+
+	unmarshal := json.Unmarshal
+	if endpoint.format() == FormatXML {
+		unmarshal = xml.Unmarshal
+	}
+
+	var status apiStatus
+	decodeErr := unmarshal(body, &status)
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return newAPIError(res, status, body)
+	}
+
+	if err := checkContentType(res, endpoint); err != nil {
+		return err
+	}
+
+	if decodeErr != nil {
+		return fmt.Errorf("error decoding response: %w", decodeErr)
+	}
+
+	if status.Status == statusError {
+		return newAPIError(res, status, body)
+	}
+
+	if err := unmarshal(body, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return nil
+}
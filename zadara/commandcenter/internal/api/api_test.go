@@ -0,0 +1,300 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/internal/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	item struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	itemsResponse struct {
+		Status string  `json:"status" xml:"status"`
+		Items  []*item `json:"items"  xml:"items>item"`
+	}
+)
+
+func itemsOf(resp *itemsResponse) []*item {
+	return resp.Items
+}
+
+func nameOf(i *item) string {
+	return i.Name
+}
+
+func TestEndpointURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		baseURL  string
+		endpoint api.Endpoint
+		want     string
+	}{
+		{
+			name:     "json",
+			baseURL:  "https://cc.example.com:8888",
+			endpoint: api.Endpoint{Path: []string{"api", "clouds", "cc1", "zioses"}},
+			want:     "https://cc.example.com:8888/api/clouds/cc1/zioses.json",
+		},
+		{
+			name:     "escaped cloud name",
+			baseURL:  "https://cc.example.com:8888/",
+			endpoint: api.Endpoint{Path: []string{"api", "clouds", "cloud one/../two?", "zioses"}},
+			want:     "https://cc.example.com:8888/api/clouds/cloud%20one%2F..%2Ftwo%3F/zioses.json",
+		},
+		{
+			name:    "query and xml",
+			baseURL: "https://cc.example.com:8888/prefix",
+			endpoint: api.Endpoint{
+				Path:   []string{"api", "clouds", "cc1", "zioses"},
+				Query:  url.Values{"page": {"2"}, "per_page": {"10"}},
+				Format: api.FormatXML,
+			},
+			want: "https://cc.example.com:8888/prefix/api/clouds/cc1/zioses.xml?page=2&per_page=10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.endpoint.URL(tt.baseURL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/clouds/cloud one/items.json", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"status": "success", "items": [{"name": "item1"}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := api.Get[itemsResponse](context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "clouds", "cloud one", "items"}})
+	require.NoError(t, err)
+	assert.Equal(t, &itemsResponse{Status: "success", Items: []*item{{Name: "item1"}}}, resp)
+}
+
+func TestGet_SuccessStatus(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status": "success", "items": [{"name": "item1"}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := api.Get[itemsResponse](context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}})
+	require.NoError(t, err)
+	assert.Equal(t, &itemsResponse{Status: "success", Items: []*item{{Name: "item1"}}}, resp)
+}
+
+func TestGetXML(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/items.xml", r.URL.Path)
+		assert.Equal(t, "application/xml", r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", "application/xml")
+
+		if r.URL.Query().Get("fail") != "" {
+			_, _ = w.Write([]byte(`<response><status>error</status><message>Invalid filter</message></response>`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`<response><status>success</status><items><item><name>item1</name></item></items></response>`))
+	}))
+	defer server.Close()
+
+	endpoint := api.Endpoint{Path: []string{"api", "items"}, Format: api.FormatXML}
+
+	resp, err := api.Get[itemsResponse](context.Background(), server.Client(), server.URL, endpoint)
+	require.NoError(t, err)
+	assert.Equal(t, &itemsResponse{Status: "success", Items: []*item{{Name: "item1"}}}, resp)
+
+	endpoint.Query = url.Values{"fail": {"true"}}
+
+	_, err = api.Get[itemsResponse](context.Background(), server.Client(), server.URL, endpoint)
+
+	var apiErr *api.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "Invalid filter", apiErr.Message)
+}
+
+func TestGetUnexpectedContentType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		format      api.Format
+		contentType string
+		body        string
+	}{
+		{
+			name:        "xml for json",
+			contentType: "application/xml",
+			body:        `<response><status>success</status><items><item><name>item1</name></item></items></response>`,
+		},
+		{
+			name:        "html for json",
+			contentType: "text/html; charset=utf-8",
+			body:        `<html><body>Sign in</body></html>`,
+		},
+		{
+			name:        "json for xml",
+			format:      api.FormatXML,
+			contentType: "application/json",
+			body:        `{"status": "success", "items": [{"name": "item1"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := api.Get[itemsResponse](context.Background(), server.Client(), server.URL,
+				api.Endpoint{Path: []string{"api", "items"}, Format: tt.format})
+			require.ErrorIs(t, err, api.ErrUnexpectedContentType)
+		})
+	}
+}
+
+func TestGetPlainText(t *testing.T) {
+	t.Parallel()
+
+	// Servers that do not set a content type have it sniffed as text/plain.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "success", "items": [{"name": "item1"}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := api.Get[itemsResponse](context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}})
+	require.NoError(t, err)
+	assert.Equal(t, &itemsResponse{Status: "success", Items: []*item{{Name: "item1"}}}, resp)
+}
+
+func TestGetMaxBodySize(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "success", "items": [{"name": "` + strings.Repeat("a", 100) + `"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := api.Get[itemsResponse](context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}, MaxBodySize: 64})
+	require.ErrorIs(t, err, api.ErrBodyTooLarge)
+
+	_, err = api.Get[itemsResponse](context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}})
+	require.NoError(t, err)
+}
+
+func TestGetPages(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]string{
+		"1": `{"status": "success", "items": [{"name": "item1"}, {"name": "item2"}]}`,
+		"2": `{"status": "success", "items": [{"name": "item3"}, {"name": "item4"}]}`,
+		"3": `{"status": "success", "items": [{"name": "item5"}]}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2", r.URL.Query().Get("per_page"))
+		assert.Equal(t, "ok", r.URL.Query().Get("status"))
+
+		_, _ = w.Write([]byte(pages[r.URL.Query().Get("page")]))
+	}))
+	defer server.Close()
+
+	items, err := api.GetPages(context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}, Query: url.Values{"status": {"ok"}}}, 2,
+		itemsOf, nameOf)
+	require.NoError(t, err)
+	assert.Equal(t, []*item{{"item1"}, {"item2"}, {"item3"}, {"item4"}, {"item5"}}, items)
+}
+
+func TestGetPages_PaginationIgnored(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+
+	// The server returns all the items for every page.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+
+		_, _ = w.Write([]byte(`{"status": "success", "items": [{"name": "item1"}, {"name": "item2"}, {"name": "item3"}]}`))
+	}))
+	defer server.Close()
+
+	items, err := api.GetPages(context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}}, 2, itemsOf, nameOf)
+	require.NoError(t, err)
+	assert.Equal(t, []*item{{"item1"}, {"item2"}, {"item3"}}, items)
+	assert.Equal(t, int64(2), requests.Load())
+}
+
+func TestGetPages_EmptyPage(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "1" {
+			_, _ = w.Write([]byte(`{"status": "success", "items": [{"name": "item1"}, {"name": "item2"}]}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"status": "success", "items": []}`))
+	}))
+	defer server.Close()
+
+	items, err := api.GetPages(context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}}, 2, itemsOf, nameOf)
+	require.NoError(t, err)
+	assert.Equal(t, []*item{{"item1"}, {"item2"}}, items)
+}
+
+func TestGetPages_TooManyPages(t *testing.T) {
+	t.Parallel()
+
+	// The server returns a full page of new items for every page.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "success", "items": [{"name": "` + r.URL.Query().Get("page") + `"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := api.GetPages(context.Background(), server.Client(), server.URL,
+		api.Endpoint{Path: []string{"api", "items"}}, 1, itemsOf, nameOf)
+	require.ErrorIs(t, err, api.ErrTooManyPages)
+}
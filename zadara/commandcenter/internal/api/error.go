@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// APIError is returned when the API responds with an error, either with an HTTP error status
+	// or with an error status in the response. It wraps ErrResponse.
+	APIError struct {
+		// StatusCode is the HTTP status code of the response.
+		StatusCode int
+
+		// Endpoint is the method and path of the request, such as GET /api/clouds/cc1/zioses.json.
+		Endpoint string
+
+		// Status and Message are the status and message of the response, if it is a Zadara API response.
+		Status  string
+		Message string
+
+		// Body is the start of the response body, if it is not a Zadara API response.
+		Body string
+
+		// Retryable is whether the request may succeed if retried, for rate limited requests and server errors.
+		Retryable bool
+	}
+
+	// apiStatus represents the status and message included in every API response.
+	apiStatus struct {
+		Status  string `json:"status"  xml:"status"`
+		Message string `json:"message" xml:"message"`
+	}
+)
+
+// statusError is the status of responses reporting an error.
+const statusError = "error"
+
+// maxBodySnippet is the number of bytes of a response body kept in an APIError.
+const maxBodySnippet = 256
+
+// ErrResponse is an error returned when the response contains an error.
+var ErrResponse = errors.New("error in response")
+
+// Error returns the endpoint, status and message or body of the response.
+func (e *APIError) Error() string {
+	detail := e.Message
+	if detail == "" {
+		detail = e.Body
+	}
+
+	msg := fmt.Sprintf("%s: %s returned %d %s", ErrResponse, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if detail != "" {
+		msg += ": " + detail
+	}
+
+	return msg
+}
+
+// Unwrap returns ErrResponse, so API errors match it with errors.Is.
+func (e *APIError) Unwrap() error {
+	return ErrResponse
+}
+
+// IsUnauthorized reports whether the error is an API error for a missing, invalid or insufficient token.
+func IsUnauthorized(err error) bool {
+	var apiErr *APIError
+
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// IsNotFound reports whether the error is an API error for a cloud or object store that does not exist.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsRateLimited reports whether the error is an API error for a rate limited request.
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsServerError reports whether the error is an API error for a failure of the server or a proxy in front of it.
+func IsServerError(err error) bool {
+	var apiErr *APIError
+
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= http.StatusInternalServerError
+}
+
+// IsRetryable reports whether the error is an API error for a request that may succeed if retried.
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+
+	return errors.As(err, &apiErr) && apiErr.Retryable
+}
+
+// snippet returns the start of the body, truncated to maxBodySnippet bytes.
+func snippet(body []byte) string {
+	s := strings.TrimSpace(string(body))
+	if len(s) <= maxBodySnippet {
+		return s
+	}
+
+	s = s[:maxBodySnippet]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+
+	return s + "..."
+}
+
+// newAPIError returns the API error for the response to the request.
+func newAPIError(res *http.Response, status apiStatus, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Status:     status.Status,
+		Message:    status.Message,
+		Retryable:  res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError,
+	}
+
+	if res.Request != nil {
+		apiErr.Endpoint = res.Request.Method + " " + res.Request.URL.Path
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Body = snippet(body)
+	}
+
+	return apiErr
+}
@@ -0,0 +1,20 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/internal/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorWrapped(t *testing.T) {
+	t.Parallel()
+
+	err := errors.Join(errors.New("error getting stores"), &api.APIError{StatusCode: http.StatusForbidden})
+
+	assert.True(t, api.IsUnauthorized(err))
+	assert.False(t, api.IsNotFound(err))
+	assert.False(t, api.IsUnauthorized(errors.New("error getting stores")))
+}
@@ -0,0 +1,195 @@
+package commandcenter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/krystal/zadara-exporter/config"
+	"golang.org/x/time/rate"
+)
+
+type (
+	// RateLimitConfig configures the rate limit of the requests to each Command Center.
+	RateLimitConfig struct {
+		// RequestsPerSecond is the rate of requests to each Command Center. The requests are not limited if zero.
+		RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+
+		// Burst is the number of requests that can be sent at once, defaulting to the rate rounded up.
+		Burst int `mapstructure:"burst"`
+	}
+
+	// Limiter limits the requests to a Command Center with a token bucket rate limiter and a circuit breaker.
+	Limiter struct {
+		rate    *rate.Limiter
+		breaker *Breaker
+
+		rateLimited atomic.Int64
+		circuitOpen atomic.Int64
+	}
+
+	// LimiterStats represents the state of the limiter of a Command Center.
+	LimiterStats struct {
+		// CommandCenter is the host of the Command Center.
+		CommandCenter string
+
+		// State is the state of the circuit breaker.
+		State BreakerState
+
+		// RateLimited is the number of requests rejected by the rate limiter.
+		RateLimited int64
+
+		// CircuitOpen is the number of requests rejected by the circuit breaker.
+		CircuitOpen int64
+	}
+
+	// Limiters holds the limiter of each Command Center, so the limits apply across all the clients
+	// of all the targets of a Command Center.
+	Limiters struct {
+		rateLimit RateLimitConfig
+		breaker   BreakerConfig
+
+		mu       sync.Mutex
+		limiters map[string]*Limiter
+	}
+
+	// limiterTransport rejects the requests not allowed by the limiter and records their outcome.
+	limiterTransport struct {
+		limiter *Limiter
+		next    http.RoundTripper
+	}
+)
+
+// ErrRateLimited is returned when a request is rejected because it would not be allowed by the rate limit
+// before its context is done.
+var ErrRateLimited = errors.New("rate limited")
+
+// NewLimiters returns the limiters of the Command Centers with the configuration.
+func NewLimiters(rateLimit RateLimitConfig, breaker BreakerConfig) *Limiters {
+	return &Limiters{
+		rateLimit: rateLimit,
+		breaker:   breaker,
+		limiters:  map[string]*Limiter{},
+	}
+}
+
+// newLimiter returns a limiter with the configuration.
+func newLimiter(rateLimit RateLimitConfig, breaker BreakerConfig) *Limiter {
+	limit := rate.Inf
+	burst := rateLimit.Burst
+
+	if rateLimit.RequestsPerSecond > 0 {
+		limit = rate.Limit(rateLimit.RequestsPerSecond)
+
+		if burst <= 0 {
+			burst = int(math.Ceil(rateLimit.RequestsPerSecond))
+		}
+	}
+
+	return &Limiter{
+		rate:    rate.NewLimiter(limit, burst),
+		breaker: NewBreaker(breaker),
+	}
+}
+
+// CommandCenterHost returns the host of the Command Center of the target, which its limiter is keyed by,
+// or its URL if it has no host.
+func CommandCenterHost(target *config.Target) string {
+	u, err := url.Parse(target.URL)
+	if err != nil || u.Host == "" {
+		return target.URL
+	}
+
+	return strings.ToLower(u.Host)
+}
+
+// Limiter returns the limiter of the target's Command Center, creating it on first use.
+func (l *Limiters) Limiter(target *config.Target) *Limiter {
+	host := CommandCenterHost(target)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[host]
+	if !ok {
+		limiter = newLimiter(l.rateLimit, l.breaker)
+		l.limiters[host] = limiter
+	}
+
+	return limiter
+}
+
+// Stats returns the state of the limiters of the Command Centers that have been sent requests,
+// sorted by Command Center.
+func (l *Limiters) Stats() []LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make([]LimiterStats, 0, len(l.limiters))
+	for host, limiter := range l.limiters {
+		stats = append(stats, LimiterStats{
+			CommandCenter: host,
+			State:         limiter.breaker.State(),
+			RateLimited:   limiter.rateLimited.Load(),
+			CircuitOpen:   limiter.circuitOpen.Load(),
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].CommandCenter < stats[j].CommandCenter
+	})
+
+	return stats
+}
+
+// Wait waits until the request is allowed by the circuit breaker and the rate limit.
+// It returns ErrCircuitOpen if the circuit is open, or ErrRateLimited if the request would not be allowed
+// before the context is done. Requests that are allowed have to be recorded with Record.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if err := l.breaker.Allow(); err != nil {
+		l.circuitOpen.Add(1)
+
+		return err
+	}
+
+	if err := l.rate.Wait(ctx); err != nil {
+		l.rateLimited.Add(1)
+		l.breaker.Cancel()
+
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+
+	return nil
+}
+
+// Record records whether an allowed request succeeded. Rate limited responses and server errors are failures.
+func (l *Limiter) Record(res *http.Response, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Requests cancelled by the caller do not say anything about the Command Center.
+		l.breaker.Cancel()
+	case err != nil:
+		l.breaker.Record(false)
+	default:
+		l.breaker.Record(res.StatusCode != http.StatusTooManyRequests && res.StatusCode < http.StatusInternalServerError)
+	}
+}
+
+// RoundTrip sends the request if the limiter allows it.
+func (t *limiterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	res, err := t.next.RoundTrip(req)
+	t.limiter.Record(res, err)
+
+	return res, err //nolint:wrapcheck // the errors are wrapped by the next transport
+}
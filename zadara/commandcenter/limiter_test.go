@@ -0,0 +1,95 @@
+package commandcenter_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitersCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	limiters := commandcenter.NewLimiters(commandcenter.RateLimitConfig{}, commandcenter.BreakerConfig{
+		FailureThreshold: 3,
+		OpenTimeout:      time.Hour,
+	})
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer other.Close()
+
+	target := &config.Target{Name: "London", URL: server.URL, CloudName: "cc1"}
+
+	// The limiter is shared by the clients of the target.
+	for range 5 {
+		_, err := commandcenter.NewClient(target, commandcenter.WithLimiters(limiters)).
+			GetStores(context.Background(), "cc1")
+		require.Error(t, err)
+	}
+
+	assert.Equal(t, int64(3), requests.Load())
+
+	_, err := commandcenter.NewClient(target, commandcenter.WithLimiters(limiters)).
+		GetStores(context.Background(), "cc1")
+	require.ErrorIs(t, err, commandcenter.ErrCircuitOpen)
+
+	// The other targets of the Command Center share its limiter.
+	_, err = commandcenter.NewClient(&config.Target{Name: "Paris", URL: server.URL + "/"},
+		commandcenter.WithLimiters(limiters)).GetStores(context.Background(), "cc2")
+	require.ErrorIs(t, err, commandcenter.ErrCircuitOpen)
+
+	// Other Command Centers have their own limiter.
+	_, err = commandcenter.NewClient(&config.Target{Name: "New York", URL: other.URL},
+		commandcenter.WithLimiters(limiters)).GetStores(context.Background(), "cc1")
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []commandcenter.LimiterStats{
+		{CommandCenter: server.Listener.Addr().String(), State: commandcenter.BreakerOpen, CircuitOpen: 4},
+		{CommandCenter: other.Listener.Addr().String(), State: commandcenter.BreakerClosed},
+	}, limiters.Stats())
+}
+
+func TestLimitersRateLimit(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	limiters := commandcenter.NewLimiters(commandcenter.RateLimitConfig{RequestsPerSecond: 0.1, Burst: 1},
+		commandcenter.DefaultBreakerConfig)
+	client := commandcenter.NewClient(&config.Target{Name: "London", URL: server.URL},
+		commandcenter.WithLimiters(limiters))
+
+	_, err := client.GetStores(context.Background(), "cc1")
+	require.NoError(t, err)
+
+	// The next request would not be allowed before the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = client.GetStores(ctx, "cc1")
+	require.ErrorIs(t, err, commandcenter.ErrRateLimited)
+
+	assert.Equal(t, []commandcenter.LimiterStats{
+		{CommandCenter: server.Listener.Addr().String(), State: commandcenter.BreakerClosed, RateLimited: 1},
+	}, limiters.Stats())
+}
@@ -0,0 +1,255 @@
+package commandcenter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type (
+	// Recording represents a recorded request to the Command Center API and its response.
+	Recording struct {
+		Request  RecordedRequest  `json:"request"`
+		Response RecordedResponse `json:"response"`
+	}
+
+	// RecordedRequest represents a recorded request, without its credentials.
+	RecordedRequest struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header,omitempty"`
+	}
+
+	// RecordedResponse represents a recorded response.
+	// The body is kept as JSON when it is valid JSON, so the recordings are easy to read and edit.
+	RecordedResponse struct {
+		StatusCode int             `json:"status_code"`
+		Header     http.Header     `json:"header,omitempty"`
+		Body       json.RawMessage `json:"body,omitempty"`
+		BodyText   string          `json:"body_text,omitempty"`
+	}
+
+	// recordingTransport records the requests and responses passing through it to a directory.
+	recordingTransport struct {
+		dir  string
+		next http.RoundTripper
+
+		mu sync.Mutex
+	}
+
+	// replayTransport serves the responses recorded in a directory.
+	replayTransport struct {
+		dir string
+	}
+)
+
+// ErrNoRecording is returned when replaying a request that was not recorded.
+var ErrNoRecording = errors.New("no recording for request")
+
+//nolint:gochecknoglobals // headers removed from recordings as they carry credentials
+var sensitiveHeaders = []string{"X-Token", "Authorization", "Cookie", "Set-Cookie"}
+
+//nolint:gochecknoglobals // query parameters masked in recordings as they carry credentials
+var sensitiveQueryParams = []string{
+	"token", "access_token", "api_key", "access_key", "secret_key", "secret", "password",
+	"signature", "x-amz-credential", "x-amz-signature", "x-amz-security-token",
+}
+
+//nolint:gochecknoglobals // characters replaced in recording file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// NewRecordingTransport returns a transport that sends requests with the next transport, defaulting to
+// http.DefaultTransport, and writes each request and response to a JSON file in the directory.
+// Credentials are removed from the recordings, and a later recording of the same request replaces the earlier one.
+func NewRecordingTransport(dir string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &recordingTransport{dir: dir, next: next}
+}
+
+// NewReplayTransport returns a transport that serves the responses recorded in the directory
+// by NewRecordingTransport, without sending any requests.
+func NewReplayTransport(dir string) http.RoundTripper {
+	return &replayTransport{dir: dir}
+}
+
+// recordingKey returns the key identifying the request, ignoring its credentials.
+func recordingKey(req *http.Request) string {
+	return req.Method + " " + req.URL.Host + req.URL.RequestURI()
+}
+
+// recordingFile returns the file the request is recorded to.
+// The name is readable, with a hash of the key so different requests never share a file.
+func recordingFile(dir string, req *http.Request) string {
+	key := recordingKey(req)
+	sum := sha256.Sum256([]byte(key))
+
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(req.Method+req.URL.Path, "_"), "_")
+
+	const hashLength = 8
+
+	return filepath.Join(dir, name+"_"+hex.EncodeToString(sum[:hashLength/2])+".json")
+}
+
+func sanitize(header http.Header) http.Header {
+	header = header.Clone()
+
+	for _, name := range sensitiveHeaders {
+		header.Del(name)
+	}
+
+	if len(header) == 0 {
+		return nil
+	}
+
+	return header
+}
+
+// redactURL returns the URL with its password and the values of sensitive query parameters masked.
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	masked := false
+
+	for name, values := range query {
+		if !slices.Contains(sensitiveQueryParams, strings.ToLower(name)) {
+			continue
+		}
+
+		for i := range values {
+			values[i] = "xxxxx"
+		}
+
+		masked = true
+	}
+
+	if !masked {
+		return u.Redacted()
+	}
+
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+
+	return redacted.Redacted()
+}
+
+// RoundTrip sends the request and records it along with its response.
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by the token transport
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if closeErr := res.Body.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading response for recording: %w", err)
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	recording := &Recording{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    redactURL(req.URL),
+			Header: sanitize(req.Header),
+		},
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     sanitize(res.Header),
+		},
+	}
+
+	if json.Valid(body) {
+		recording.Response.Body = body
+	} else {
+		recording.Response.BodyText = string(body)
+	}
+
+	if err := t.write(recordingFile(t.dir, req), recording); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// write writes the recording to the file, replacing it atomically.
+func (t *recordingTransport) write(path string, recording *Recording) error {
+	data, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding recording: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := os.MkdirAll(t.dir, 0o750); err != nil { //nolint:mnd // directory permissions
+		return fmt.Errorf("error creating recording directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil { //nolint:mnd // file permissions
+		return fmt.Errorf("error writing recording: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing recording: %w", err)
+	}
+
+	return nil
+}
+
+// RoundTrip returns the recorded response to the request.
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	data, err := os.ReadFile(recordingFile(t.dir, req))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoRecording, recordingKey(req))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading recording: %w", err)
+	}
+
+	var recording Recording
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return nil, fmt.Errorf("error decoding recording: %w", err)
+	}
+
+	body := []byte(recording.Response.BodyText)
+	if len(recording.Response.Body) > 0 {
+		body = recording.Response.Body
+	}
+
+	header := recording.Response.Header
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recording.Response.StatusCode, http.StatusText(recording.Response.StatusCode)),
+		StatusCode:    recording.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
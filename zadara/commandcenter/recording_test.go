@@ -0,0 +1,113 @@
+package commandcenter_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret-token", r.Header.Get("X-Token"))
+
+		var response any
+
+		switch r.URL.Path {
+		case "/api/clouds/cc1/zioses.json":
+			response = vpsaobjectstorage.ZiosResponse{
+				Status: "success",
+				Zioses: []*vpsaobjectstorage.Zios{{ID: 1, Name: "store1"}},
+				Count:  1,
+			}
+		case "/api/clouds/cc1/zioses/1/storage_policies.json":
+			response = vpsaobjectstorage.ZiosStoragePoliciesResponse{
+				Status: "success",
+				ZiosStoragePolicies: []*vpsaobjectstorage.ZiosStoragePolicy{
+					{ID: 1, Name: "policy1", FreeCapacity: 100, UsedCapacity: 50},
+				},
+				Count: 1,
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+
+	dir := t.TempDir()
+	target := &config.Target{URL: server.URL, CloudName: "cc1", Token: "secret-token"}
+
+	recorder := commandcenter.NewClient(target,
+		commandcenter.WithTransport(commandcenter.NewRecordingTransport(dir, nil)))
+
+	recorded, err := recorder.GetAllStoragePolicies(context.Background())
+	require.NoError(t, err)
+
+	// The API is no longer needed to replay the responses.
+	server.Close()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "secret-token", "the token is stripped from %s", file)
+	}
+
+	replayer := commandcenter.NewClient(target,
+		commandcenter.WithTransport(commandcenter.NewReplayTransport(dir)))
+
+	replayed, err := replayer.GetAllStoragePolicies(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	_, err = replayer.GetStoragePolicies(context.Background(), "cc1", 2)
+	require.ErrorIs(t, err, commandcenter.ErrNoRecording)
+}
+
+func TestRecordingTransport_MasksQueryCredentials(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := &http.Client{Transport: commandcenter.NewRecordingTransport(dir, nil)}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+		server.URL+"/api/zioses.json?page=2&Token=secret-token&access_key=secret-key", nil)
+	require.NoError(t, err)
+
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(data), "secret-token")
+	assert.NotContains(t, string(data), "secret-key")
+	assert.Contains(t, string(data), "page=2")
+}
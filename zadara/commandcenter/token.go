@@ -1,22 +1,73 @@
 package commandcenter
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"net/http"
+
+	"github.com/krystal/zadara-exporter/config"
 )
 
 type (
+	// TokenSource resolves the tokens of the targets, such as tokens referencing a secret manager.
+	TokenSource interface {
+		// Token returns the token to send for the token of the target.
+		Token(ctx context.Context, token string) (string, error)
+
+		// Invalidate discards the resolved token of the target's token, such as when it was rejected.
+		Invalidate(token string)
+	}
+
 	// addTokenHeaderTransport represents a transport that adds a token header to the request.
 	addTokenHeaderTransport struct {
-		T     http.RoundTripper
-		token string
+		T      http.RoundTripper
+		target string
+		token  string
+		source TokenSource
 	}
 )
 
-// RoundTrip executes a single HTTP transaction, adding the X-Token header to the request.
-// It returns the response received from the server or an error if the request fails.
-func (t *addTokenHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Add("X-Token", t.token)
+// fingerprintLength is the number of hex characters of the token hash in a fingerprint.
+const fingerprintLength = 12
+
+// TokenFingerprint returns a fingerprint identifying the token in logs without revealing it:
+// the start of the hex encoded SHA-256 hash of the token.
+func TokenFingerprint(token string) string {
+	if token == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(token))
+
+	return "sha256:" + hex.EncodeToString(sum[:])[:fingerprintLength]
+}
+
+// rejected reports whether the Command Center rejected the token of the request.
+func rejected(res *http.Response) bool {
+	return res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden
+}
+
+// resolve returns the token to send, resolved by the token source if set.
+func (t *addTokenHeaderTransport) resolve(ctx context.Context) (string, error) {
+	if t.source == nil {
+		return t.token, nil
+	}
+
+	token, err := t.source.Token(ctx, t.token)
+	if err != nil {
+		return "", fmt.Errorf("error resolving token: %w", err)
+	}
+
+	return token, nil
+}
+
+// send sends a copy of the request with the X-Token header.
+func (t *addTokenHeaderTransport) send(req *http.Request, token string) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Token", token)
 
 	res, err := t.T.RoundTrip(req)
 	if err != nil {
@@ -26,15 +77,88 @@ func (t *addTokenHeaderTransport) RoundTrip(req *http.Request) (*http.Response,
 	return res, nil
 }
 
+// RoundTrip executes a single HTTP transaction, adding the X-Token header to the request.
+// If the token is resolved by a token source and rejected, it is resolved again and the request retried once
+// with the new token, so rotated secrets are picked up before the cached token expires.
+// Rejected tokens are logged with their fingerprint, so the credential to rotate can be identified.
+// It returns the response received from the server or an error if the request fails.
+func (t *addTokenHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.resolve(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.send(req, token)
+	if err != nil || !rejected(res) {
+		return res, err
+	}
+
+	if t.source != nil && req.Body == nil {
+		t.source.Invalidate(t.token)
+
+		refreshed, resolveErr := t.resolve(req.Context())
+		if resolveErr == nil && refreshed != token {
+			if closeErr := res.Body.Close(); closeErr != nil {
+				slog.Error("error closing response body", "error", closeErr)
+			}
+
+			token = refreshed
+
+			res, err = t.send(req, token)
+			if err != nil || !rejected(res) {
+				return res, err
+			}
+		}
+	}
+
+	slog.Warn("Command Center rejected token",
+		"name", t.target,
+		"host", req.URL.Host,
+		"status", res.StatusCode,
+		"token_fingerprint", TokenFingerprint(token))
+
+	return res, nil
+}
+
 // newAddTokenHeaderTransport creates a new transport that adds a token header to each request.
 // If the provided roundTripper is nil, it defaults to http.DefaultTransport.
 func newAddTokenHeaderTransport(
 	roundTripper http.RoundTripper,
-	token string,
+	target *config.Target,
+	source TokenSource,
 ) *addTokenHeaderTransport {
 	if roundTripper == nil {
 		roundTripper = http.DefaultTransport
 	}
 
-	return &addTokenHeaderTransport{T: roundTripper, token: token}
+	return &addTokenHeaderTransport{T: roundTripper, target: target.Name, token: target.Token, source: source}
+}
+
+// VerifyToken checks the Command Center accepts the token of the target, by requesting its clouds if they
+// are discovered or the stores of its cloud otherwise.
+func (c *Client) VerifyToken(ctx context.Context, target *config.Target) error {
+	var err error
+	if target.DiscoverClouds.Enabled {
+		_, err = c.GetClouds(ctx)
+	} else {
+		_, err = c.GetStores(ctx, target.CloudName)
+	}
+
+	return err
+}
+
+// TokenFingerprint returns the fingerprint of the token the client sends, resolved by the token source if set,
+// so it matches the fingerprint of the credential rather than of its reference. It is empty if the token cannot
+// be resolved.
+func (c *Client) TokenFingerprint(ctx context.Context) string {
+	if c.tokens == nil {
+		return TokenFingerprint(c.token)
+	}
+
+	token, err := c.tokens.Token(ctx, c.token)
+	if err != nil {
+		return ""
+	}
+
+	return TokenFingerprint(token)
 }
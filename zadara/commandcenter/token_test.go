@@ -0,0 +1,139 @@
+package commandcenter_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/config"
+	"github.com/krystal/zadara-exporter/secrets"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter"
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/internal/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSource(t *testing.T) {
+	t.Parallel()
+
+	var (
+		validToken atomic.Value
+		requests   atomic.Int64
+		resolved   atomic.Int64
+	)
+
+	validToken.Store("token-1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.Header.Get("X-Token") != validToken.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"status": "error", "message": "Invalid token"}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	resolver := secrets.NewResolver(secrets.Config{})
+	resolver.Register("test", secrets.ProviderFunc(func(_ context.Context, _ string) (string, error) {
+		return "token-" + strconv.FormatInt(resolved.Add(1), 10), nil
+	}))
+
+	client := commandcenter.NewClient(&config.Target{URL: server.URL, Token: "${test:london}"},
+		commandcenter.WithTokenSource(resolver))
+
+	_, err := client.GetStores(context.Background(), "cc1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), requests.Load())
+
+	// The fingerprint is of the resolved token, not of its reference.
+	assert.Equal(t, commandcenter.TokenFingerprint("token-1"), client.TokenFingerprint(context.Background()))
+
+	// A rotated token is resolved again when the cached token is rejected.
+	validToken.Store("token-2")
+
+	_, err = client.GetStores(context.Background(), "cc1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), requests.Load())
+	assert.Equal(t, int64(2), resolved.Load())
+	assert.Equal(t, commandcenter.TokenFingerprint("token-2"), client.TokenFingerprint(context.Background()))
+
+	// The request is retried once.
+	validToken.Store("revoked")
+
+	_, err = client.GetStores(context.Background(), "cc1")
+	require.Error(t, err)
+	assert.True(t, api.IsUnauthorized(err))
+	assert.Equal(t, int64(5), requests.Load())
+}
+
+func TestTokenSource_Error(t *testing.T) {
+	t.Parallel()
+
+	client := commandcenter.NewClient(&config.Target{URL: "http://127.0.0.1:0", Token: "${aws:london}"},
+		commandcenter.WithTokenSource(secrets.NewResolver(secrets.Config{})))
+
+	_, err := client.GetStores(context.Background(), "cc1")
+	require.ErrorIs(t, err, secrets.ErrUnknownProvider)
+	assert.Empty(t, client.TokenFingerprint(context.Background()))
+}
+
+func TestTokenFingerprint(t *testing.T) {
+	t.Parallel()
+
+	fingerprint := commandcenter.TokenFingerprint("secret-token")
+
+	assert.Regexp(t, `^sha256:[0-9a-f]{12}$`, fingerprint)
+	assert.NotContains(t, fingerprint, "secret-token")
+	assert.Equal(t, fingerprint, commandcenter.TokenFingerprint("secret-token"))
+	assert.NotEqual(t, fingerprint, commandcenter.TokenFingerprint("other-token"))
+	assert.Empty(t, commandcenter.TokenFingerprint(""))
+
+	client := commandcenter.NewClient(&config.Target{URL: "http://127.0.0.1:0", Token: "secret-token"})
+	assert.Equal(t, fingerprint, client.TokenFingerprint(context.Background()))
+}
+
+func TestVerifyToken(t *testing.T) {
+	t.Parallel()
+
+	var paths []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"status": "error", "message": "Invalid token"}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	target := &config.Target{Name: "London", URL: server.URL, CloudName: "cc1", Token: "secret"}
+	require.NoError(t, commandcenter.NewClient(target).VerifyToken(context.Background(), target))
+
+	discovered := &config.Target{
+		Name:           "London",
+		URL:            server.URL,
+		Token:          "secret",
+		DiscoverClouds: config.CloudDiscovery{Enabled: true},
+	}
+	require.NoError(t, commandcenter.NewClient(discovered).VerifyToken(context.Background(), discovered))
+
+	assert.Equal(t, []string{"/api/clouds/cc1/zioses.json", "/api/clouds.json"}, paths)
+
+	revoked := &config.Target{Name: "London", URL: server.URL, CloudName: "cc1", Token: "revoked"}
+	err := commandcenter.NewClient(revoked).VerifyToken(context.Background(), revoked)
+	require.Error(t, err)
+	assert.True(t, api.IsUnauthorized(err))
+}
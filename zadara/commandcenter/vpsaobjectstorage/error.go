@@ -1,6 +1,34 @@
 package vpsaobjectstorage
 
-import "errors"
+import "github.com/krystal/zadara-exporter/zadara/commandcenter/internal/api"
+
+// APIError is returned when the API responds with an error. It wraps ErrResponse.
+type APIError = api.APIError
 
 // ErrResponse is an error returned when the response contains an error.
-var ErrResponse = errors.New("error in response")
+var ErrResponse = api.ErrResponse
+
+// IsUnauthorized reports whether the error is an API error for a missing, invalid or insufficient token.
+func IsUnauthorized(err error) bool {
+	return api.IsUnauthorized(err)
+}
+
+// IsNotFound reports whether the error is an API error for a cloud or object store that does not exist.
+func IsNotFound(err error) bool {
+	return api.IsNotFound(err)
+}
+
+// IsRateLimited reports whether the error is an API error for a rate limited request.
+func IsRateLimited(err error) bool {
+	return api.IsRateLimited(err)
+}
+
+// IsServerError reports whether the error is an API error for a failure of the server or a proxy in front of it.
+func IsServerError(err error) bool {
+	return api.IsServerError(err)
+}
+
+// IsRetryable reports whether the error is an API error for a request that may succeed if retried.
+func IsRetryable(err error) bool {
+	return api.IsRetryable(err)
+}
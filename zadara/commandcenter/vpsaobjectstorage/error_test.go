@@ -0,0 +1,147 @@
+package vpsaobjectstorage_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/vpsaobjectstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:funlen // most of this length is due to the test data
+func TestAPIError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		statusCode    int
+		body          string
+		want          vpsaobjectstorage.APIError
+		wantError     string
+		unauthorized  bool
+		notFound      bool
+		rateLimited   bool
+		serverError   bool
+		wantRetryable bool
+	}{
+		{
+			name:       "unauthorized",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"status": "error", "message": "Invalid token"}`,
+			want: vpsaobjectstorage.APIError{
+				StatusCode: http.StatusUnauthorized,
+				Endpoint:   "GET /api/clouds/cc1/zioses.json",
+				Status:     "error",
+				Message:    "Invalid token",
+			},
+			wantError:    "error in response: GET /api/clouds/cc1/zioses.json returned 401 Unauthorized: Invalid token",
+			unauthorized: true,
+		},
+		{
+			name:       "not found",
+			statusCode: http.StatusNotFound,
+			body:       `{"status": "error", "message": "Cloud not found"}`,
+			want: vpsaobjectstorage.APIError{
+				StatusCode: http.StatusNotFound,
+				Endpoint:   "GET /api/clouds/cc1/zioses.json",
+				Status:     "error",
+				Message:    "Cloud not found",
+			},
+			wantError: "error in response: GET /api/clouds/cc1/zioses.json returned 404 Not Found: Cloud not found",
+			notFound:  true,
+		},
+		{
+			name:       "rate limited",
+			statusCode: http.StatusTooManyRequests,
+			body:       "slow down\n",
+			want: vpsaobjectstorage.APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Endpoint:   "GET /api/clouds/cc1/zioses.json",
+				Body:       "slow down",
+				Retryable:  true,
+			},
+			wantError:     "error in response: GET /api/clouds/cc1/zioses.json returned 429 Too Many Requests: slow down",
+			rateLimited:   true,
+			wantRetryable: true,
+		},
+		{
+			name:       "html gateway error",
+			statusCode: http.StatusBadGateway,
+			body:       "<html><body><h1>502 Bad Gateway</h1></body></html>",
+			want: vpsaobjectstorage.APIError{
+				StatusCode: http.StatusBadGateway,
+				Endpoint:   "GET /api/clouds/cc1/zioses.json",
+				Body:       "<html><body><h1>502 Bad Gateway</h1></body></html>",
+				Retryable:  true,
+			},
+			wantError: "error in response: GET /api/clouds/cc1/zioses.json returned 502 Bad Gateway: " +
+				"<html><body><h1>502 Bad Gateway</h1></body></html>",
+			serverError:   true,
+			wantRetryable: true,
+		},
+		{
+			name:       "error status",
+			statusCode: http.StatusOK,
+			body:       `{"status": "error", "message": "Something went wrong"}`,
+			want: vpsaobjectstorage.APIError{
+				StatusCode: http.StatusOK,
+				Endpoint:   "GET /api/clouds/cc1/zioses.json",
+				Status:     "error",
+				Message:    "Something went wrong",
+			},
+			wantError: "error in response: GET /api/clouds/cc1/zioses.json returned 200 OK: Something went wrong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := vpsaobjectstorage.NewClient(server.URL, server.Client())
+
+			_, err := client.GetStores(context.Background(), "cc1")
+			require.ErrorIs(t, err, vpsaobjectstorage.ErrResponse)
+
+			var apiErr *vpsaobjectstorage.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.want, *apiErr)
+			assert.EqualError(t, err, tt.wantError)
+
+			assert.Equal(t, tt.unauthorized, vpsaobjectstorage.IsUnauthorized(err))
+			assert.Equal(t, tt.notFound, vpsaobjectstorage.IsNotFound(err))
+			assert.Equal(t, tt.rateLimited, vpsaobjectstorage.IsRateLimited(err))
+			assert.Equal(t, tt.serverError, vpsaobjectstorage.IsServerError(err))
+			assert.Equal(t, tt.wantRetryable, vpsaobjectstorage.IsRetryable(err))
+		})
+	}
+}
+
+func TestAPIErrorBodySnippet(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(strings.Repeat("é", 1000)))
+	}))
+	defer server.Close()
+
+	client := vpsaobjectstorage.NewClient(server.URL, server.Client())
+
+	_, err := client.GetStoragePolicies(context.Background(), "cc1", 188)
+
+	var apiErr *vpsaobjectstorage.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "GET /api/clouds/cc1/zioses/188/storage_policies.json", apiErr.Endpoint)
+	assert.Equal(t, strings.Repeat("é", 128)+"...", apiErr.Body)
+	assert.True(t, vpsaobjectstorage.IsServerError(err))
+}
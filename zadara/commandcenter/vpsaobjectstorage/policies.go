@@ -2,12 +2,9 @@ package vpsaobjectstorage
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log/slog"
-	"net/http"
-	"path"
 	"strconv"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/internal/api"
 )
 
 type (
@@ -68,32 +65,7 @@ func (c *Client) GetStoragePolicies(
 	ctx context.Context,
 	cloudName string, ziosID int,
 ) (*ZiosStoragePoliciesResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
-		c.BaseURL+path.Join("/api/clouds", cloudName, "zioses", strconv.Itoa(ziosID), "storage_policies.json"),
-		nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
-	}
-
-	res, err := c.C.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error in sending request: %w", err)
-	}
-
-	defer func() {
-		if err := res.Body.Close(); err != nil {
-			slog.Error("error closing response body", "error", err)
-		}
-	}()
-
-	var resp ZiosStoragePoliciesResponse
-	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	if res.StatusCode != http.StatusOK || resp.Status == "error" {
-		return nil, fmt.Errorf("%w: %s", ErrResponse, resp.Message)
-	}
-
-	return &resp, nil
+	return api.Get[ZiosStoragePoliciesResponse](ctx, c.C, c.BaseURL, api.Endpoint{
+		Path: []string{"api", "clouds", cloudName, "zioses", strconv.Itoa(ziosID), "storage_policies"},
+	})
 }
@@ -2,11 +2,8 @@ package vpsaobjectstorage
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log/slog"
-	"net/http"
-	"path"
+
+	"github.com/krystal/zadara-exporter/zadara/commandcenter/internal/api"
 )
 
 type (
@@ -61,6 +58,7 @@ type (
 // It sends an HTTP GET request to the Zadara API to fetch the stores information.
 // The cloudName parameter specifies the name of the cloud.
 // The function returns a pointer to the ZiosResponse and an error, if any.
+// If the client's PerPage is set, the stores are requested a page at a time and combined into one response.
 //
 // # API Docs
 //
@@ -77,33 +75,22 @@ func (c *Client) GetStores(
 	ctx context.Context,
 	cloudName string,
 ) (*ZiosResponse, error) {
-	req, err := http.NewRequestWithContext(ctx,
-		http.MethodGet,
-		c.BaseURL+path.Join("/api/clouds", cloudName, "zioses.json"),
-		nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
-	}
-
-	res, err := c.C.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error in sending request: %w", err)
-	}
+	endpoint := api.Endpoint{Path: []string{"api", "clouds", cloudName, "zioses"}}
 
-	defer func() {
-		if err := res.Body.Close(); err != nil {
-			slog.Error("error closing response body", "error", err)
-		}
-	}()
-
-	var resp ZiosResponse
-	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+	if c.PerPage <= 0 {
+		return api.Get[ZiosResponse](ctx, c.C, c.BaseURL, endpoint)
 	}
 
-	if res.StatusCode != http.StatusOK || resp.Status == "error" {
-		return nil, fmt.Errorf("%w: %s", ErrResponse, resp.Message)
+	zioses, err := api.GetPages(ctx, c.C, c.BaseURL, endpoint, c.PerPage,
+		func(resp *ZiosResponse) []*Zios {
+			return resp.Zioses
+		},
+		func(zios *Zios) int {
+			return zios.ID
+		})
+	if err != nil {
+		return nil, err
 	}
 
-	return &resp, nil
+	return &ZiosResponse{Status: "success", Zioses: zioses, Count: len(zioses)}, nil
 }
@@ -52,6 +52,33 @@ func TestClient_GetStores(t *testing.T) {
 	assert.Equal(t, 2, resp.Count)
 }
 
+func TestClient_GetStoresPaginated(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zioses := []*vpsaobjectstorage.Zios{{ID: 1, Name: "zios1"}, {ID: 2, Name: "zios2"}}
+		if r.URL.Query().Get("page") == "2" {
+			zioses = []*vpsaobjectstorage.Zios{{ID: 3, Name: "zios3"}}
+		}
+
+		assert.Equal(t, "2", r.URL.Query().Get("per_page"))
+		require.NoError(t, json.NewEncoder(w).Encode(vpsaobjectstorage.ZiosResponse{Status: "success", Zioses: zioses}))
+	}))
+	defer server.Close()
+
+	client := &vpsaobjectstorage.Client{
+		C:       server.Client(),
+		BaseURL: server.URL,
+		PerPage: 2,
+	}
+
+	resp, err := client.GetStores(context.Background(), "cloudName")
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.Count)
+	assert.Equal(t, []*vpsaobjectstorage.Zios{{ID: 1, Name: "zios1"}, {ID: 2, Name: "zios2"}, {ID: 3, Name: "zios3"}},
+		resp.Zioses)
+}
+
 //nolint:funlen // most of this length is due to the test data
 func TestZiosResponse(t *testing.T) {
 	t.Parallel()
@@ -11,6 +11,10 @@ type (
 		BaseURL   string
 		C         *http.Client
 		CloudName string
+
+		// PerPage is the number of records requested per page from paginated endpoints.
+		// All the records are requested at once if zero.
+		PerPage int
 	}
 )
 